@@ -1,8 +1,11 @@
 // godevwatch live reload client
 ;(function () {
   const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+  const backend = (document.currentScript && document.currentScript.dataset.backend) || ''
+  // Version of the message protocol this client understands, see /.godevwatch/protocol.schema.json
+  const PROTOCOL_VERSION = 1
   let ws = null
-  let hasSeenBuilds = false
+  let unsupported = false
 
   // Create notification element
   const notification = document.createElement('div')
@@ -17,6 +20,19 @@
     max-width: 300px;
   `
 
+  // Create collapsible live build log panel
+  const MAX_LOG_LINES = 500
+  const logPanel = document.createElement('details')
+  logPanel.id = 'godevwatch-log'
+  logPanel.innerHTML = '<summary></summary><pre></pre>'
+  const logSummary = logPanel.querySelector('summary')
+  const logOutput = logPanel.querySelector('pre')
+  let logBuildId = ''
+  let logRule = ''
+
+  // Dispatch a cancelable event on window, returning false if a listener called preventDefault()
+  const notify = (name, detail) => window.dispatchEvent(new CustomEvent(name, { detail, cancelable: true }))
+
   const formatBuildId = (id) => {
     const parts = id.split('-')
     if (parts.length < 2) return id
@@ -31,45 +47,145 @@
     return `${hours}:${minutes}:${seconds}.${ms}`
   }
 
+  const showNotification = () => {
+    if (!document.body.contains(notification)) {
+      document.body.appendChild(notification)
+    }
+  }
+
   const updateBuildStatus = (builds) => {
-    if (builds.length > 0) {
-      hasSeenBuilds = true
-      notification.innerHTML = builds
-        .reverse()
-        .map((b) => {
-          const spinner = b.status === 'building' ? '<div class="spinner"></div>' : ''
-          const formattedId = formatBuildId(b.id)
-          let bgColor, textColor
-          if (b.status === 'building') {
-            bgColor = '#fef3c7'
-            textColor = '#92400e'
-          } else if (b.status === 'failed') {
-            bgColor = '#fee2e2'
-            textColor = '#991b1b'
-          } else {
-            bgColor = '#d1fae5'
-            textColor = '#065f46'
-          }
-          return `<div style="padding: 0.5rem; margin-bottom: 0.25rem; font-family: monospace; display: flex; align-items: center; background: ${bgColor}; color: ${textColor}; border-radius: 0.25rem;">${formattedId} - ${b.status}${spinner}</div>`
-        })
-        .join('')
-      if (!document.body.contains(notification)) {
-        document.body.appendChild(notification)
-      }
-    } else {
-      if (hasSeenBuilds) {
-        notification.innerHTML = `<div style="padding: 0.75rem 1rem; font-family: monospace; display: flex; align-items: center; background: #bfdbfe; color: #1e3a8a; border-radius: 0.25rem;">refreshing<div class="spinner"></div></div>`
-        if (!document.body.contains(notification)) {
-          document.body.appendChild(notification)
+    if (builds.length === 0) {
+      notification.remove()
+      return
+    }
+
+    notification.innerHTML = builds
+      .reverse()
+      .map((b) => {
+        const spinner = b.status === 'building' ? '<div class="spinner"></div>' : ''
+        const formattedId = formatBuildId(b.id)
+        let bgColor, textColor
+        if (b.status === 'building') {
+          bgColor = '#fef3c7'
+          textColor = '#92400e'
+        } else if (b.status === 'failed') {
+          bgColor = '#fee2e2'
+          textColor = '#991b1b'
+        } else {
+          bgColor = '#d1fae5'
+          textColor = '#065f46'
         }
-        // Reload when build completes
-        setTimeout(() => location.reload(), 500)
-      } else {
-        notification.remove()
-      }
+        return `<div style="padding: 0.5rem; margin-bottom: 0.25rem; font-family: monospace; display: flex; align-items: center; background: ${bgColor}; color: ${textColor}; border-radius: 0.25rem;">${formattedId} - ${b.status}${spinner}</div>`
+      })
+      .join('')
+    showNotification()
+  }
+
+  const setLogSummary = (text) => {
+    logSummary.textContent = text
+  }
+
+  const startLog = (data) => {
+    logBuildId = data.build_id
+    logRule = ''
+    logOutput.textContent = ''
+    setLogSummary(`Build ${formatBuildId(data.build_id)}: ${data.rules.join(', ')}`)
+    if (!document.body.contains(logPanel)) {
+      document.body.appendChild(logPanel)
+    }
+  }
+
+  const appendLog = (data) => {
+    if (data.build_id !== logBuildId) return
+    if (data.rule !== logRule) {
+      logRule = data.rule
+      setLogSummary(`Build ${formatBuildId(data.build_id)}: ${data.rule}`)
+    }
+
+    const line = document.createElement('div')
+    line.className = data.dropped ? 'dropped' : data.stream
+    line.textContent = data.line
+    logOutput.appendChild(line)
+    while (logOutput.childElementCount > MAX_LOG_LINES) {
+      logOutput.firstChild.remove()
+    }
+    logOutput.scrollTop = logOutput.scrollHeight
+  }
+
+  const finishLog = (data) => {
+    if (data.build_id !== logBuildId) return
+    if (data.status === 'failed') {
+      // Keep the output of a failed build visible
+      setLogSummary(`Build ${formatBuildId(data.build_id)} failed in ${data.rule}`)
+      showBuildError(data)
+    } else {
+      logPanel.remove()
+    }
+  }
+
+  const reload = (data) => {
+    notification.innerHTML = `<div style="padding: 0.75rem 1rem; font-family: monospace; display: flex; align-items: center; background: #bfdbfe; color: #1e3a8a; border-radius: 0.25rem;">refreshing<div class="spinner"></div></div>`
+    showNotification()
+    // Reload after a successful build unless the page handles it itself
+    if (notify('godevwatch:rebuilt', data)) {
+      location.reload()
+    } else {
+      setTimeout(() => notification.remove(), 500)
     }
   }
 
+  // Full-page overlay shown when the app crashes or a build fails
+  const overlay = document.createElement('div')
+  overlay.id = 'godevwatch-crash'
+
+  const describeCrash = (data) => {
+    let status = data.signal ? `killed by ${data.signal}` : `exited with code ${data.exit_code}`
+    if (data.crash_loop) {
+      status += ` - crash loop, gave up after ${data.attempt - 1} restarts`
+    } else if (data.restarting) {
+      status += ` - restarting in ${(data.retry_in_ms / 1000).toFixed(1)}s (attempt ${data.attempt})`
+    }
+    return status
+  }
+
+  // source is the process that crashed, or "build" for a failed build
+  const showOverlay = (source, title, status, output) => {
+    overlay.dataset.process = source
+    overlay.innerHTML = '<div class="panel"><div class="header"><strong></strong><button type="button">×</button></div><div class="status"></div><pre></pre></div>'
+    overlay.querySelector('strong').textContent = title
+    overlay.querySelector('.status').textContent = status
+    overlay.querySelector('pre').textContent = output
+    overlay.querySelector('button').addEventListener('click', () => overlay.remove())
+    if (!document.body.contains(overlay)) {
+      document.body.appendChild(overlay)
+    }
+    const pre = overlay.querySelector('pre')
+    pre.scrollTop = pre.scrollHeight
+  }
+
+  const showCrash = (data) => {
+    const process = data.process || 'app'
+    showOverlay(process, process === 'app' ? 'Application crashed' : `${process} crashed`, describeCrash(data), data.output.join('\n'))
+  }
+
+  // Shown over the page when the previous version of the app keeps serving after a failed build
+  const showBuildError = (data) => {
+    const output = Array.from(logOutput.children, (line) => line.textContent).join('\n')
+    showOverlay('build', `Build failed in ${data.rule}`, data.error || '', output)
+  }
+
+  // Mirror the app's output to the devtools console unless turned off with
+  // localStorage.setItem('godevwatch:app-logs', 'off')
+  let appLogsOff = false
+  try {
+    appLogsOff = localStorage.getItem('godevwatch:app-logs') === 'off'
+  } catch {}
+  const logAppOutput = (data) => {
+    if (appLogsOff) return
+    const log = data.stream === 'stderr' ? console.warn : console.log
+    log(`%c${data.process || 'app'}`, 'color: #1e3a8a; font-weight: 600', data.line)
+  }
+
   // Add spinner styles
   const style = document.createElement('style')
   style.textContent = `
@@ -83,6 +199,81 @@
       margin-left: 0.5rem;
       flex-shrink: 0;
     }
+    #godevwatch-log {
+      position: fixed;
+      bottom: 1rem;
+      right: 1rem;
+      z-index: 10000;
+      width: min(40rem, calc(100vw - 2rem));
+      background: #1f2937;
+      color: #e5e7eb;
+      border-radius: 0.25rem;
+      font-family: monospace;
+      font-size: 0.75rem;
+    }
+    #godevwatch-log summary {
+      padding: 0.5rem 0.75rem;
+      cursor: pointer;
+    }
+    #godevwatch-log pre {
+      margin: 0;
+      padding: 0 0.75rem 0.5rem;
+      max-height: 40vh;
+      overflow: auto;
+      white-space: pre-wrap;
+    }
+    #godevwatch-log .stderr {
+      color: #fca5a5;
+    }
+    #godevwatch-log .dropped {
+      color: #9ca3af;
+      font-style: italic;
+    }
+    #godevwatch-crash {
+      position: fixed;
+      inset: 0;
+      z-index: 10001;
+      background: rgba(0, 0, 0, 0.6);
+      display: flex;
+      align-items: center;
+      justify-content: center;
+      font-family: monospace;
+      font-size: 0.75rem;
+    }
+    #godevwatch-crash .panel {
+      width: min(60rem, calc(100vw - 2rem));
+      max-height: calc(100vh - 2rem);
+      display: flex;
+      flex-direction: column;
+      background: #1f2937;
+      color: #e5e7eb;
+      border-top: 4px solid #dc2626;
+      border-radius: 0.25rem;
+    }
+    #godevwatch-crash .header {
+      display: flex;
+      justify-content: space-between;
+      padding: 0.75rem 1rem 0.25rem;
+      font-size: 0.875rem;
+      color: #fca5a5;
+    }
+    #godevwatch-crash button {
+      background: none;
+      border: none;
+      color: inherit;
+      font-size: 1rem;
+      cursor: pointer;
+    }
+    #godevwatch-crash .status {
+      padding: 0 1rem 0.5rem;
+    }
+    #godevwatch-crash pre {
+      margin: 0;
+      padding: 0.75rem 1rem;
+      overflow: auto;
+      white-space: pre-wrap;
+      border-top: 1px solid #374151;
+    }
     @keyframes godevwatch-spinner-spin {
       0% { transform: rotate(0deg); }
       100% { transform: rotate(360deg); }
@@ -91,14 +282,60 @@
   document.head.appendChild(style)
 
   function connect() {
-    ws = new WebSocket(`${protocol}//${window.location.host}/.godevwatch-ws`)
+    ws = new WebSocket(`${protocol}//${window.location.host}/.godevwatch-ws?protocol=${PROTOCOL_VERSION}`)
 
     ws.onmessage = (event) => {
-      const data = JSON.parse(event.data)
-      if (data.type === 'server-status' && data.status === 'down') {
-        location.reload()
-      } else if (data.type === 'build-status') {
-        updateBuildStatus(data.builds || [])
+      const message = JSON.parse(event.data)
+      const data = message.data || {}
+      switch (message.type) {
+        case 'hello':
+          if (data.error) {
+            unsupported = true
+            console.warn(`godevwatch: ${data.error}, server supports protocol ${data.min_protocol}-${data.max_protocol}`)
+          }
+          break
+        case 'server-status':
+          // Only react to the backend serving this page
+          if (backend && data.backend && data.backend !== backend) return
+          if (data.status === 'down') {
+            // Pages that reconnect their own streams can call preventDefault() to stay loaded
+            if (notify('godevwatch:server-down', data)) location.reload()
+          } else {
+            notify('godevwatch:server-up', data)
+          }
+          break
+        case 'build-status':
+          updateBuildStatus(data.builds || [])
+          break
+        case 'build-started':
+          if (overlay.dataset.process === 'build') {
+            overlay.remove()
+          }
+          startLog(data)
+          break
+        case 'build-output':
+          // Pages opened mid-build receive the buffered output without a build-started
+          if (!logBuildId) startLog({ build_id: data.build_id, rules: [data.rule] })
+          appendLog(data)
+          break
+        case 'build-finished':
+          finishLog(data)
+          break
+        case 'reload':
+          logPanel.remove()
+          reload(data)
+          break
+        case 'app-output':
+          logAppOutput(data)
+          break
+        case 'app-crash':
+          showCrash(data)
+          break
+        case 'app-started':
+          if (overlay.dataset.process === (data.process || 'app')) {
+            overlay.remove()
+          }
+          break
       }
     }
 
@@ -107,7 +344,7 @@
     }
 
     ws.onclose = () => {
-      setTimeout(connect, 2000)
+      if (!unsupported) setTimeout(connect, 2000)
     }
   }
 
@@ -0,0 +1,349 @@
+package godevwatch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// defaultBackendName is the name of the backend built from BackendPort
+const defaultBackendName = "default"
+
+// Backend is an upstream server the proxy forwards requests to
+type Backend struct {
+	route    Route
+	target   atomic.Pointer[url.URL]
+	proxy    *httputil.ReverseProxy
+	client   *http.Client
+	conns    map[*trackedConn]bool
+	inflight map[string]int
+	mu       sync.Mutex
+}
+
+// targetKey carries the target chosen for a request to the proxy director
+type targetKey struct{}
+
+// BackendStatus reports the readiness of a single backend
+type BackendStatus struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+}
+
+// NewBackend creates a backend for the given route
+func NewBackend(route Route) (*Backend, error) {
+	b := &Backend{
+		route:    route,
+		conns:    make(map[*trackedConn]bool),
+		inflight: make(map[string]int),
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dial := (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+
+	var target *url.URL
+	switch {
+	case route.Socket != "":
+		// Requests are sent over the socket, the host is only used for the Host header
+		target = &url.URL{Scheme: "http", Host: "unix"}
+		socket := route.Socket
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	case route.URL != "":
+		parsed, err := url.Parse(route.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL for route %q: %w", route.Name, err)
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid URL for route %q: scheme and host are required", route.Name)
+		}
+		target = parsed
+	case route.Port != 0:
+		target = &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", route.Port)}
+	default:
+		return nil, fmt.Errorf("route %q must set one of port, url or socket", route.Name)
+	}
+
+	// Track upstream connections so long-lived streams can be closed on restart
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return b.track(conn, addr), nil
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = transport
+
+	// Send each request to the target it was started with, which changes when
+	// a port backend is switched to a new app process
+	singleHost := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		singleHost(req)
+		if current, ok := req.Context().Value(targetKey{}).(*url.URL); ok {
+			req.URL.Host = current.Host
+		}
+	}
+
+	// Flush immediately so server-sent events and other streams are not buffered
+	proxy.FlushInterval = -1
+
+	if route.StripPrefix && route.PathPrefix != "" {
+		director := proxy.Director
+		prefix := strings.TrimSuffix(route.PathPrefix, "/")
+		proxy.Director = func(req *http.Request) {
+			req.URL.Path = ensureLeadingSlash(strings.TrimPrefix(req.URL.Path, prefix))
+			if req.URL.RawPath != "" {
+				req.URL.RawPath = ensureLeadingSlash(strings.TrimPrefix(req.URL.RawPath, prefix))
+			}
+			director(req)
+		}
+	}
+
+	b.target.Store(target)
+	b.proxy = proxy
+	b.client = &http.Client{Transport: transport, Timeout: time.Second}
+
+	return b, nil
+}
+
+// Name returns the route name of the backend
+func (b *Backend) Name() string {
+	return b.route.Name
+}
+
+// Matches checks if a request should be routed to this backend
+func (b *Backend) Matches(r *http.Request) bool {
+	if b.route.Host != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !strings.EqualFold(host, b.route.Host) {
+			return false
+		}
+	}
+
+	if b.route.PathPrefix != "" {
+		prefix := strings.TrimSuffix(b.route.PathPrefix, "/")
+		if r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// specificity ranks routes so host matches beat path matches, and longer prefixes win
+func (b *Backend) specificity() int {
+	score := len(strings.TrimSuffix(b.route.PathPrefix, "/"))
+	if b.route.Host != "" {
+		score += 10000
+	}
+	return score
+}
+
+// ServeHTTP forwards a request to the backend's current target
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := b.target.Load()
+
+	b.mu.Lock()
+	b.inflight[target.Host]++
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.inflight[target.Host]--
+		b.mu.Unlock()
+	}()
+
+	b.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), targetKey{}, target)))
+}
+
+// Port returns the local port a port backend forwards to, or 0 for other backends
+func (b *Backend) Port() int {
+	if b.route.Port == 0 {
+		return 0
+	}
+	port, _ := strconv.Atoi(b.target.Load().Port())
+	return port
+}
+
+// SetPort switches a port backend to a different local port. New requests go
+// to the new port while requests already in flight finish on the old one.
+func (b *Backend) SetPort(port int) {
+	b.target.Store(&url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", port)})
+}
+
+// Drain waits for requests to the given port to finish, then closes the
+// connections still open to it, such as WebSockets and event streams
+func (b *Backend) Drain(port int, timeout time.Duration) {
+	host := fmt.Sprintf("localhost:%d", port)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		active := b.inflight[host]
+		b.mu.Unlock()
+		if active == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	b.closeConnections(func(tc *trackedConn) bool { return tc.addr == host })
+}
+
+// IsReady checks whether the backend is accepting requests
+func (b *Backend) IsReady() bool {
+	return b.probe(b.target.Load(), 0)
+}
+
+// IsReadyOnPort checks whether a port backend would accept requests on another
+// port from the process group pgid, rather than from any process listening on it
+func (b *Backend) IsReadyOnPort(port, pgid int) bool {
+	return b.probe(&url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", port)}, pgid)
+}
+
+// probe checks whether target is accepting requests, from a listener in the
+// process group pgid unless it is 0
+func (b *Backend) probe(target *url.URL, pgid int) bool {
+	if b.route.HealthCheck != "" {
+		probe := *target
+		probe.Path = ensureLeadingSlash(b.route.HealthCheck)
+		resp, err := b.client.Get(probe.String())
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode < http.StatusInternalServerError
+	}
+
+	if b.route.Socket != "" {
+		conn, err := net.DialTimeout("unix", b.route.Socket, time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	if b.route.Port != 0 {
+		cmd := exec.Command("lsof", "-i", ":"+target.Port(), "-sTCP:LISTEN", "-t")
+		output, err := cmd.Output()
+		return err == nil && listenerInGroup(string(output), pgid)
+	}
+
+	conn, err := net.DialTimeout("tcp", target.Host, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// listenerInGroup reports whether any of the listening process IDs printed by
+// lsof -t belongs to the process group pgid, or any at all if pgid is 0
+func listenerInGroup(pids string, pgid int) bool {
+	for _, field := range strings.Fields(pids) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		if pgid == 0 {
+			return true
+		}
+		if group, err := syscall.Getpgid(pid); err == nil && group == pgid {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the current readiness of the backend
+func (b *Backend) Status() BackendStatus {
+	status := "down"
+	if b.IsReady() {
+		status = "running"
+	}
+
+	target := b.target.Load().String()
+	if b.route.Socket != "" {
+		target = "unix:" + b.route.Socket
+	}
+
+	return BackendStatus{
+		Name:   b.route.Name,
+		Target: target,
+		Status: status,
+	}
+}
+
+// track registers an upstream connection to addr until it is closed
+func (b *Backend) track(conn net.Conn, addr string) net.Conn {
+	tc := &trackedConn{Conn: conn, backend: b, addr: addr}
+	b.mu.Lock()
+	b.conns[tc] = true
+	b.mu.Unlock()
+	return tc
+}
+
+// CloseConnections closes every open upstream connection, ending proxied
+// WebSockets and event streams so clients notice the backend restarted
+func (b *Backend) CloseConnections() int {
+	return b.closeConnections(func(*trackedConn) bool { return true })
+}
+
+// closeConnections closes the open upstream connections accepted by match
+func (b *Backend) closeConnections(match func(*trackedConn) bool) int {
+	b.mu.Lock()
+	conns := make([]*trackedConn, 0, len(b.conns))
+	for tc := range b.conns {
+		if match(tc) {
+			conns = append(conns, tc)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, tc := range conns {
+		tc.Close()
+	}
+	return len(conns)
+}
+
+// trackedConn is an upstream connection that unregisters itself when closed
+type trackedConn struct {
+	net.Conn
+	backend *Backend
+	addr    string
+	once    sync.Once
+}
+
+// Close closes the connection and removes it from the backend
+func (tc *trackedConn) Close() error {
+	tc.once.Do(func() {
+		tc.backend.mu.Lock()
+		delete(tc.backend.conns, tc)
+		tc.backend.mu.Unlock()
+	})
+	return tc.Conn.Close()
+}
+
+// ensureLeadingSlash makes sure a URL path is absolute
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
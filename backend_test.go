@@ -0,0 +1,253 @@
+package godevwatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNewBackendValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   Route
+		wantErr string
+	}{
+		{"port", Route{Name: "api", Port: 8080}, ""},
+		{"url", Route{Name: "api", URL: "http://localhost:9000"}, ""},
+		{"socket", Route{Name: "api", Socket: "/tmp/api.sock"}, ""},
+		{"no target", Route{Name: "api"}, `route "api" must set one of port, url or socket`},
+		{"url without scheme", Route{Name: "api", URL: "localhost:9000"}, "scheme and host are required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBackend(tt.route)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchBackend(t *testing.T) {
+	var backends []*Backend
+	for _, route := range []Route{
+		{Name: "default", Port: 8080},
+		{Name: "api", PathPrefix: "/api/", Port: 8081},
+		{Name: "api-v2", PathPrefix: "/api/v2", Port: 8082},
+		{Name: "admin", Host: "admin.localhost", Port: 8083},
+	} {
+		backend, err := NewBackend(route)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backends = append(backends, backend)
+	}
+	ps := &ProxyServer{backends: backends}
+
+	tests := []struct {
+		host string
+		path string
+		want string
+	}{
+		{"localhost:3000", "/", "default"},
+		{"localhost:3000", "/api", "api"},
+		{"localhost:3000", "/api/users", "api"},
+		{"localhost:3000", "/apidocs", "default"},
+		{"localhost:3000", "/api/v2/users", "api-v2"},
+		{"admin.localhost:3000", "/api/users", "admin"},
+		{"ADMIN.localhost", "/", "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Host = tt.host
+			if got := ps.matchBackend(r); got == nil || got.Name() != tt.want {
+				t.Fatalf("matched %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenerInGroup(t *testing.T) {
+	pid := os.Getpid()
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pids string
+		pgid int
+		want bool
+	}{
+		{"no listener", "", 0, false},
+		{"any listener", "4242\n", 0, true},
+		{"listener in group", fmt.Sprintf("%d\n", pid), pgid, true},
+		{"listener in another group", fmt.Sprintf("%d\n", pid), pgid + 1, false},
+		{"one of several listeners", fmt.Sprintf("999999\n%d\n", pid), pgid, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listenerInGroup(tt.pids, tt.pgid); got != tt.want {
+				t.Fatalf("listenerInGroup(%q, %d) = %v, want %v", tt.pids, tt.pgid, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendStripPrefix(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name  string
+		strip bool
+		path  string
+		want  string
+	}{
+		{"prefix kept", false, "/api/users", "/api/users"},
+		{"prefix stripped", true, "/api/users", "/users"},
+		{"prefix root", true, "/api", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewBackend(Route{Name: "api", PathPrefix: "/api/", URL: upstream.URL, StripPrefix: tt.strip})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			backend.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("upstream path = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendStreams(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				kind, message, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				conn.WriteMessage(kind, message)
+			}
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: first\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer upstream.Close()
+
+	backend, err := NewBackend(Route{Name: "api", URL: upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inspector := NewRequestInspector(InspectorConfig{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streams pass through the inspector's capture writer like other requests
+		cw := inspector.Capture(w, r)
+		backend.ServeHTTP(cw, r)
+		cw.Finish()
+	}))
+	defer proxy.Close()
+
+	tests := []struct {
+		name string
+		open func(t *testing.T) (read func() error)
+	}{
+		{"websocket", func(t *testing.T) func() error {
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxy.URL, "http")+"/ws", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { conn.Close() })
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			if _, message, err := conn.ReadMessage(); err != nil || string(message) != "ping" {
+				t.Fatalf("echo = %q, %v", message, err)
+			}
+			return func() error {
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, _, err := conn.ReadMessage()
+				return err
+			}
+		}},
+		{"server-sent events", func(t *testing.T) func() error {
+			resp, err := http.Get(proxy.URL + "/events")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { resp.Body.Close() })
+
+			// The first event arrives while the stream is still open
+			line, err := bufio.NewReader(resp.Body).ReadString('\n')
+			if err != nil || line != "data: first\n" {
+				t.Fatalf("first line = %q, %v", line, err)
+			}
+			return func() error {
+				_, err := io.ReadAll(resp.Body)
+				if err == nil {
+					return io.EOF
+				}
+				return err
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := tt.open(t)
+
+			// A restarting backend closes its open upstream streams
+			if closed := backend.CloseConnections(); closed != 1 {
+				t.Fatalf("closed %d connections, want 1", closed)
+			}
+			done := make(chan error, 1)
+			go func() { done <- read() }()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Fatal("stream still open")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream not closed")
+			}
+		})
+	}
+}
@@ -28,15 +28,22 @@ type Build struct {
 // BuildTracker manages build status tracking
 type BuildTracker struct {
 	statusDir string
+	events    *EventBus
 }
 
 // NewBuildTracker creates a new build tracker
 func NewBuildTracker(statusDir string) *BuildTracker {
 	return &BuildTracker{
 		statusDir: statusDir,
+		events:    NewEventBus(),
 	}
 }
 
+// Events returns the bus that build and application lifecycle events are published on
+func (bt *BuildTracker) Events() *EventBus {
+	return bt.events
+}
+
 // NewBuild creates a new build ID and sets it as current
 func (bt *BuildTracker) NewBuild() (string, error) {
 	if err := os.MkdirAll(bt.statusDir, 0755); err != nil {
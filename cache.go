@@ -0,0 +1,161 @@
+package godevwatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// buildCacheFile is the name of the build cache in the build status directory
+const buildCacheFile = "build-cache.json"
+
+// buildCache remembers the input hash of each rule's last successful run, so a
+// rule whose inputs have not changed and whose outputs still exist can be skipped
+type buildCache struct {
+	path   string
+	hashes map[string]string
+	mu     sync.Mutex
+}
+
+// newBuildCache loads the build cache from the status directory
+func newBuildCache(statusDir string) *buildCache {
+	bc := &buildCache{
+		path:   filepath.Join(statusDir, buildCacheFile),
+		hashes: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(bc.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("\033[33mFailed to read build cache: %v\033[0m\n", err)
+		}
+		return bc
+	}
+	if err := json.Unmarshal(data, &bc.hashes); err != nil {
+		log.Printf("\033[33mIgnoring invalid build cache %s: %v\033[0m\n", bc.path, err)
+		bc.hashes = make(map[string]string)
+	}
+	return bc
+}
+
+// Fresh reports whether rule last succeeded with the same inputs and its outputs still exist
+func (bc *buildCache) Fresh(rule BuildRule, hash string) bool {
+	bc.mu.Lock()
+	last, ok := bc.hashes[rule.Name]
+	bc.mu.Unlock()
+	if !ok || last != hash || len(rule.Outputs) == 0 {
+		return false
+	}
+
+	for _, output := range rule.Outputs {
+		matches, err := filepath.Glob(output)
+		if err != nil || len(matches) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Record stores the input hash of a successful run of rule
+func (bc *buildCache) Record(rule BuildRule, hash string) error {
+	bc.mu.Lock()
+	bc.hashes[rule.Name] = hash
+	data, err := json.MarshalIndent(bc.hashes, "", "  ")
+	bc.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal build cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(bc.path), 0755); err != nil {
+		return fmt.Errorf("failed to create status directory: %w", err)
+	}
+	if err := os.WriteFile(bc.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write build cache: %w", err)
+	}
+	return nil
+}
+
+// projectFiles is a listing of the files below the working directory, walked
+// once and shared by the rules of a build
+type projectFiles struct {
+	root  string
+	files []string // Relative to root
+}
+
+// listProjectFiles walks the working directory, skipping ignored directories
+func listProjectFiles() (*projectFiles, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	listing := &projectFiles{root: cwd}
+	err = filepath.WalkDir(cwd, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip files we can't access
+		}
+		if entry.IsDir() {
+			if path != cwd && skipDir(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			listing.files = append(listing.files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find inputs: %w", err)
+	}
+	return listing, nil
+}
+
+// hashInputs hashes the command of rule and the paths and contents of every
+// listed file matching its inputs patterns
+func (fw *FileWatcher) hashInputs(rule BuildRule, listing *projectFiles) (string, error) {
+	var files []string
+	for _, rel := range listing.files {
+		for _, pattern := range rule.Inputs {
+			if matched, _ := filepath.Match(pattern, rel); matched || fw.matchesPattern(filepath.Join(listing.root, rel), pattern) {
+				files = append(files, rel)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00", rule.Command)
+	for _, file := range files {
+		sum, err := hashFile(filepath.Join(listing.root, file))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%x\x00", filepath.ToSlash(file), sum)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile returns the SHA-256 of a file's contents
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,155 @@
+package godevwatch
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes content to a file below dir, creating its directories
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashInputs(t *testing.T) {
+	rule := BuildRule{Name: "css", Command: "sass", Inputs: []string{"styles/*.scss", "**/*.lock"}}
+
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, dir string)
+		rule    BuildRule
+		changed bool
+	}{
+		{"unchanged", func(t *testing.T, dir string) {}, rule, false},
+		{"touched with the same contents", func(t *testing.T, dir string) {
+			writeFile(t, dir, "styles/app.scss", "body {}")
+		}, rule, false},
+		{"unrelated file", func(t *testing.T, dir string) {
+			writeFile(t, dir, "main.go", "package main // changed")
+		}, rule, false},
+		{"skipped directory", func(t *testing.T, dir string) {
+			writeFile(t, dir, "node_modules/dep/yarn.lock", "changed")
+		}, rule, false},
+		{"input contents", func(t *testing.T, dir string) {
+			writeFile(t, dir, "styles/app.scss", "body { color: red }")
+		}, rule, true},
+		{"input added", func(t *testing.T, dir string) {
+			writeFile(t, dir, "styles/new.scss", "")
+		}, rule, true},
+		{"input removed", func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, "deps/pkg.lock"))
+		}, rule, true},
+		{"input renamed", func(t *testing.T, dir string) {
+			os.Rename(filepath.Join(dir, "styles/app.scss"), filepath.Join(dir, "styles/main.scss"))
+		}, rule, true},
+		{"command", func(t *testing.T, dir string) {}, BuildRule{Name: "css", Command: "sass --minify", Inputs: rule.Inputs}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			writeFile(t, dir, "styles/app.scss", "body {}")
+			writeFile(t, dir, "deps/pkg.lock", "v1")
+			writeFile(t, dir, "main.go", "package main")
+
+			fw := &FileWatcher{}
+			hash := func(rule BuildRule) string {
+				t.Helper()
+				listing, err := listProjectFiles()
+				if err != nil {
+					t.Fatal(err)
+				}
+				sum, err := fw.hashInputs(rule, listing)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return sum
+			}
+			before := hash(rule)
+			tt.change(t, dir)
+			after := hash(tt.rule)
+
+			if changed := before != after; changed != tt.changed {
+				t.Fatalf("hash changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestBuildCacheFresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []string
+		hash    string
+		fresh   bool
+	}{
+		{"same inputs", []string{"out/app.css"}, "abc", true},
+		{"glob output", []string{"out/*.css"}, "abc", true},
+		{"changed inputs", []string{"out/app.css"}, "def", false},
+		{"missing output", []string{"out/app.css", "out/app.js"}, "abc", false},
+		{"no outputs", nil, "abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			writeFile(t, dir, "out/app.css", "body {}")
+
+			cache := newBuildCache(filepath.Join(dir, "status"))
+			rule := BuildRule{Name: "css", Outputs: tt.outputs}
+			if err := cache.Record(rule, "abc"); err != nil {
+				t.Fatal(err)
+			}
+
+			if fresh := cache.Fresh(rule, tt.hash); fresh != tt.fresh {
+				t.Fatalf("Fresh = %v, want %v", fresh, tt.fresh)
+			}
+		})
+	}
+}
+
+func TestBuildCachePersisted(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, dir, "tmp/main", "binary")
+	statusDir := filepath.Join(dir, "tmp", ".build-status")
+
+	rule := BuildRule{Name: "go-build", Outputs: []string{"tmp/main"}}
+	if err := newBuildCache(statusDir).Record(rule, "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(statusDir, buildCacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes map[string]string
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		t.Fatal(err)
+	}
+	if hashes["go-build"] != "abc" {
+		t.Fatalf("persisted hashes = %v", hashes)
+	}
+
+	// A new cache, as after a restart, loads the recorded hash
+	if !newBuildCache(statusDir).Fresh(rule, "abc") {
+		t.Fatal("recorded hash not loaded")
+	}
+
+	// An invalid file is ignored
+	writeFile(t, statusDir, buildCacheFile, "{not json")
+	if newBuildCache(statusDir).Fresh(rule, "abc") {
+		t.Fatal("invalid cache file loaded")
+	}
+}
@@ -0,0 +1,101 @@
+package godevwatch
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileState is what is known about a watched file's contents
+type fileState struct {
+	size    int64
+	modTime time.Time
+	hash    []byte
+}
+
+// changeDetector remembers the contents of watched files so events that do not
+// change them, such as a save without edits or a rename-and-replace of the same
+// contents, can be ignored
+type changeDetector struct {
+	files      map[string]fileState
+	checked    int
+	suppressed int
+	mu         sync.Mutex
+}
+
+// newChangeDetector creates an empty change detector
+func newChangeDetector() *changeDetector {
+	return &changeDetector{files: make(map[string]fileState)}
+}
+
+// Seed records the current contents of a file
+func (cd *changeDetector) Seed(path string) {
+	state, ok := readFileState(path)
+	if !ok {
+		return
+	}
+	cd.mu.Lock()
+	cd.files[path] = state
+	cd.mu.Unlock()
+}
+
+// Changed reports whether the contents of a file differ from when it was last
+// seen. Files with the same size and modification time are assumed unchanged
+// without being read.
+func (cd *changeDetector) Changed(path string) bool {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	cd.checked++
+
+	last, known := cd.files[path]
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		// Removed files are a change
+		delete(cd.files, path)
+		return true
+	}
+	if known && info.Size() == last.size && info.ModTime().Equal(last.modTime) {
+		cd.suppressed++
+		return false
+	}
+
+	state, ok := readFileState(path)
+	if !ok {
+		delete(cd.files, path)
+		return true
+	}
+	cd.files[path] = state
+	if known && bytes.Equal(state.hash, last.hash) {
+		cd.suppressed++
+		return false
+	}
+	return true
+}
+
+// Len returns the number of files whose contents are known
+func (cd *changeDetector) Len() int {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	return len(cd.files)
+}
+
+// Stats returns how many changed files were checked and how many of them were unchanged
+func (cd *changeDetector) Stats() (checked, suppressed int) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	return cd.checked, cd.suppressed
+}
+
+// readFileState stats and hashes a file
+func readFileState(path string) (fileState, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return fileState{}, false
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return fileState{}, false
+	}
+	return fileState{size: info.Size(), modTime: info.ModTime(), hash: hash}, true
+}
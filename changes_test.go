@@ -0,0 +1,70 @@
+package godevwatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChangeDetector(t *testing.T) {
+	later := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name    string
+		seed    bool
+		modify  func(t *testing.T, dir string)
+		changed bool
+	}{
+		{"untouched", true, func(t *testing.T, dir string) {}, false},
+		{"touched", true, func(t *testing.T, dir string) {
+			os.Chtimes(filepath.Join(dir, "main.go"), later, later)
+		}, false},
+		{"replaced with the same contents", true, func(t *testing.T, dir string) {
+			writeFile(t, dir, "main.go.tmp", "package main")
+			os.Chtimes(filepath.Join(dir, "main.go.tmp"), later, later)
+			os.Rename(filepath.Join(dir, "main.go.tmp"), filepath.Join(dir, "main.go"))
+		}, false},
+		{"edited", true, func(t *testing.T, dir string) {
+			writeFile(t, dir, "main.go", "package app")
+		}, true},
+		{"edited keeping the size", true, func(t *testing.T, dir string) {
+			writeFile(t, dir, "main.go", "package mian")
+			os.Chtimes(filepath.Join(dir, "main.go"), later, later)
+		}, true},
+		{"removed", true, func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, "main.go"))
+		}, true},
+		{"not seen before", false, func(t *testing.T, dir string) {}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "main.go", "package main")
+			path := filepath.Join(dir, "main.go")
+
+			cd := newChangeDetector()
+			if tt.seed {
+				cd.Seed(path)
+			}
+			tt.modify(t, dir)
+
+			if changed := cd.Changed(path); changed != tt.changed {
+				t.Fatalf("Changed = %v, want %v", changed, tt.changed)
+			}
+			suppressed := 0
+			if !tt.changed {
+				suppressed = 1
+			}
+			if checked, got := cd.Stats(); checked != 1 || got != suppressed {
+				t.Fatalf("stats = %d checked, %d suppressed", checked, got)
+			}
+
+			// The new contents are remembered for the next event
+			if _, err := os.Stat(path); err == nil && cd.Changed(path) {
+				t.Fatal("unchanged file reported twice")
+			}
+		})
+	}
+}
@@ -25,12 +25,37 @@ func handleInit() {
 	fmt.Println("Created godevwatch.yaml")
 }
 
+func handleSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	output := fs.String("o", "", "Write the schema to a file instead of stdout")
+	fs.Parse(args)
+
+	schema, err := godevwatch.MessageSchema()
+	if err != nil {
+		log.Fatalf("Failed to generate schema: %v", err)
+	}
+	schema = append(schema, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*output, schema, 0644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+	fmt.Printf("Wrote %s\n", *output)
+}
+
 func main() {
-	// Handle init subcommand before flag parsing
+	// Handle subcommands before flag parsing
 	if len(os.Args) > 1 && os.Args[1] == "init" {
 		handleInit()
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		handleSchema(os.Args[2:])
+		return
+	}
 
 	// Parse flags for main command
 	var (
@@ -39,6 +64,9 @@ func main() {
 		backendPort  = flag.Int("backend-port", 8080, "Backend server port")
 		statusDir    = flag.String("status-dir", "tmp/.build-status", "Build status directory")
 		injectScript = flag.Bool("inject-script", true, "Inject live reload script into HTML responses")
+		enableTLS    = flag.Bool("tls", false, "Serve the proxy over HTTPS with local development certificates")
+		noCache      = flag.Bool("no-cache", false, "Run every build rule, ignoring the build cache")
+		verbose      = flag.Bool("verbose", false, "Log ignored file changes and other details")
 		showVersion  = flag.Bool("version", false, "Show version information")
 	)
 
@@ -74,6 +102,16 @@ func main() {
 		}
 	}
 
+	if *enableTLS {
+		config.TLS.Enabled = true
+	}
+	if *noCache {
+		config.NoCache = true
+	}
+	if *verbose {
+		config.Verbose = true
+	}
+
 	// Enable watch mode if build rules and run command are configured
 	enableWatch := len(config.BuildRules) > 0 && config.RunCmd != ""
 
@@ -96,10 +134,31 @@ func main() {
 	if err := godevwatch.KillProcessOnPort(config.BackendPort); err != nil {
 		log.Printf("Warning: Failed to clean up backend port: %v", err)
 	}
+	if config.Swap.Enabled {
+		swapPort := config.Swap.Port
+		if swapPort == 0 {
+			swapPort = config.BackendPort + 1
+		}
+		if err := godevwatch.KillProcessOnPort(swapPort); err != nil {
+			log.Printf("Warning: Failed to clean up swap port: %v", err)
+		}
+	}
+	if config.TLS.Enabled && config.TLS.RedirectPort != 0 {
+		if err := godevwatch.KillProcessOnPort(config.TLS.RedirectPort); err != nil {
+			log.Printf("Warning: Failed to clean up redirect port: %v", err)
+		}
+	}
 
 	// Create build tracker
 	buildTracker := godevwatch.NewBuildTracker(config.BuildStatusDir)
 
+	// Create proxy server with shared build tracker, before the first build so it sees every event
+	proxy, err := godevwatch.NewProxyServer(config, buildTracker)
+	if err != nil {
+		log.Fatalf("Failed to create proxy server: %v", err)
+	}
+	defer proxy.Close()
+
 	// Create file watcher if enabled
 	var watcher *godevwatch.FileWatcher
 	if enableWatch {
@@ -108,19 +167,13 @@ func main() {
 			log.Fatalf("Failed to create file watcher: %v", err)
 		}
 		defer watcher.Stop()
+		proxy.SetFileWatcher(watcher)
 
 		if err := watcher.Start(); err != nil {
 			log.Fatalf("Failed to start file watcher: %v", err)
 		}
 	}
 
-	// Create proxy server with shared build tracker
-	proxy, err := godevwatch.NewProxyServer(config, buildTracker)
-	if err != nil {
-		log.Fatalf("Failed to create proxy server: %v", err)
-	}
-	defer proxy.Close()
-
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
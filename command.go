@@ -2,11 +2,15 @@ package godevwatch
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -15,22 +19,45 @@ import (
 type Command struct {
 	cmdString string
 	cmd       *exec.Cmd
+	Name      string
+	Env       []string
+	Dir       string
+	Stop      StopConfig
 	OnStdout  func(string)
 	OnStderr  func(string)
+	OnStart   func(pid int)
+	started   chan struct{}
+	done      chan struct{}
+	running   bool
+	killed    bool
+	mu        sync.Mutex
 }
 
 // NewCommand creates a new command
 func NewCommand(cmdString string) *Command {
 	return &Command{
 		cmdString: cmdString,
+		started:   make(chan struct{}),
+		done:      make(chan struct{}),
 	}
 }
 
 // Run executes the command and waits for it to complete
 func (c *Command) Run() error {
+	defer close(c.done)
+
+	c.mu.Lock()
+	if c.killed {
+		c.mu.Unlock()
+		return fmt.Errorf("command stopped before it started")
+	}
+	c.running = true
+	c.mu.Unlock()
+
 	// Parse command string into shell execution
 	c.cmd = exec.Command("sh", "-c", c.cmdString)
-	c.cmd.Env = os.Environ()
+	c.cmd.Env = append(os.Environ(), c.Env...)
+	c.cmd.Dir = c.Dir
 
 	// Set process group so we can kill child processes
 	c.cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -50,20 +77,81 @@ func (c *Command) Run() error {
 	}
 
 	// Start the command
-	if err := c.cmd.Start(); err != nil {
+	err = c.cmd.Start()
+	close(c.started)
+	if err != nil {
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 
-	// Stream output
-	go c.streamOutput(stdout, c.OnStdout)
-	go c.streamOutput(stderr, c.OnStderr)
+	if c.OnStart != nil {
+		c.OnStart(c.cmd.Process.Pid)
+	}
+
+	// Stream output, reading everything before Wait closes the pipes so the
+	// last lines (such as a panic trace) are not lost
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.streamOutput(stdout, c.OnStdout)
+	}()
+	go func() {
+		defer wg.Done()
+		c.streamOutput(stderr, c.OnStderr)
+	}()
+	wg.Wait()
 
 	// Wait for command to complete
 	return c.cmd.Wait()
 }
 
+// Exited reports whether the command has finished running
+func (c *Command) Exited() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// PID returns the process ID once the command has started, or 0
+func (c *Command) PID() int {
+	select {
+	case <-c.started:
+	default:
+		return 0
+	}
+	if c.cmd == nil || c.cmd.Process == nil {
+		return 0
+	}
+	return c.cmd.Process.Pid
+}
+
+// ExitCode returns the exit code of a finished command, or -1 if it was killed or has not exited
+func (c *Command) ExitCode() int {
+	if c.cmd == nil || c.cmd.ProcessState == nil {
+		return -1
+	}
+	return c.cmd.ProcessState.ExitCode()
+}
+
+// Signal returns the name of the signal that terminated the command, if any
+func (c *Command) Signal() string {
+	if c.cmd == nil || c.cmd.ProcessState == nil {
+		return ""
+	}
+	if status, ok := c.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
+
 // streamOutput streams output line by line
 func (c *Command) streamOutput(reader io.Reader, callback func(string)) {
+	// Keep draining so the process never blocks on a full pipe
+	defer io.Copy(io.Discard, reader)
+
 	if callback == nil {
 		return
 	}
@@ -74,13 +162,37 @@ func (c *Command) streamOutput(reader io.Reader, callback func(string)) {
 	}
 }
 
-// Kill terminates the command and all child processes, waiting for termination
+// Kill stops the command and all child processes, waiting for termination. The
+// stop command or signal is sent first, and the process group is killed with
+// SIGKILL if it is still running after the stop timeout.
 func (c *Command) Kill() error {
-	if c.cmd == nil || c.cmd.Process == nil {
+	// A command that has not been run yet is stopped before it starts
+	c.mu.Lock()
+	if !c.running {
+		c.killed = true
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	// Let a command that is being started finish starting so it can be stopped
+	select {
+	case <-c.started:
+	case <-c.done:
+	}
+	if c.cmd.Process == nil {
+		return nil
+	}
+	select {
+	case <-c.done:
+		// Already exited
 		return nil
+	default:
 	}
 
 	pid := c.cmd.Process.Pid
+	start := time.Now()
+	timeout := c.Stop.timeout()
 
 	// Kill the process group to ensure all children are terminated
 	pgid, err := syscall.Getpgid(pid)
@@ -90,34 +202,101 @@ func (c *Command) Kill() error {
 			return err
 		}
 		// Wait for process to finish
-		c.cmd.Wait()
+		<-c.done
 		return nil
 	}
 
-	// Send SIGTERM to the process group
-	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
-		// If process doesn't exist, it's already dead
-		if err != syscall.ESRCH {
-			return err
+	// stop_timeout: 0 kills the process group without waiting
+	if timeout == 0 {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+		exited := true
+		select {
+		case <-c.done:
+		case <-time.After(time.Second):
+			exited = false
 		}
+		c.logStopped("killed with SIGKILL", exited)
+		return nil
 	}
 
-	// Wait for the process to actually terminate (with timeout)
-	done := make(chan error, 1)
-	go func() {
-		done <- c.cmd.Wait()
-	}()
+	how := ""
+	if c.Stop.Command != "" {
+		if err := c.runStopCommand(pid, timeout); err != nil {
+			log.Printf("\033[33m%s: stop_command failed: %v\033[0m\n", c.label(), err)
+		} else {
+			how = "stop_command"
+		}
+	}
+	if how == "" {
+		sig, err := c.Stop.signal()
+		if err != nil {
+			sig = syscall.SIGTERM
+		}
+		how = signalName(sig)
+		if err := syscall.Kill(-pgid, sig); err != nil {
+			// If process doesn't exist, it's already dead
+			if err != syscall.ESRCH {
+				return err
+			}
+		}
+	}
 
+	// Wait for the process to actually terminate, the stop command's time included
 	select {
-	case <-done:
-		// Process terminated successfully
-		return nil
-	case <-time.After(2 * time.Second):
+	case <-c.done:
+		c.logStopped(fmt.Sprintf("stopped by %s in %s", how, time.Since(start).Round(time.Millisecond)), true)
+	case <-time.After(timeout - time.Since(start)):
 		// Timeout - force kill
 		syscall.Kill(-pgid, syscall.SIGKILL)
-		c.cmd.Wait() // Clean up zombie
-		return nil
+
+		// A process that left the group can keep the output pipes open, so do
+		// not wait on it forever
+		exited := true
+		select {
+		case <-c.done:
+		case <-time.After(time.Second):
+			exited = false
+		}
+		c.logStopped(fmt.Sprintf("did not stop within %s of %s, killed with SIGKILL", timeout, how), exited)
+	}
+
+	return nil
+}
+
+// runStopCommand runs stop_command with the process ID in $GODEVWATCH_PID
+func (c *Command) runStopCommand(pid int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stop := exec.CommandContext(ctx, "sh", "-c", c.Stop.Command)
+	stop.Env = append(os.Environ(), c.Env...)
+	stop.Env = append(stop.Env, "GODEVWATCH_PID="+strconv.Itoa(pid))
+	stop.Dir = c.Dir
+
+	if output, err := stop.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// logStopped logs how a stopped command ended
+func (c *Command) logStopped(how string, exited bool) {
+	status := "still running"
+	if exited && c.cmd.ProcessState != nil {
+		status = c.cmd.ProcessState.String()
+	}
+	log.Printf("\033[33m%s %s (%s)\033[0m\n", c.label(), how, status)
+}
+
+// label names the command in log messages
+func (c *Command) label() string {
+	if c.Name != "" {
+		return c.Name
 	}
+	return c.cmdString
 }
 
 // parseCommand parses a command string into program and arguments
@@ -3,25 +3,178 @@ package godevwatch
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // BuildRule represents a conditional build rule
 type BuildRule struct {
-	Name    string   `yaml:"name"`
-	Watch   []string `yaml:"watch"`
-	Command string   `yaml:"command"`
+	Name     string         `yaml:"name"`
+	Watch    []string       `yaml:"watch"`
+	Command  string         `yaml:"command"`
+	Inputs   []string       `yaml:"inputs,omitempty"`
+	Outputs  []string       `yaml:"outputs,omitempty"`
+	Debounce DebounceConfig `yaml:"debounce,omitempty"`
+	OnChange string         `yaml:"on_change,omitempty"`
+	Stop     StopConfig     `yaml:",inline"`
+}
+
+// DebounceConfig controls how bursts of file changes are grouped into builds
+type DebounceConfig struct {
+	Delay   time.Duration `yaml:"delay,omitempty"`
+	MaxWait time.Duration `yaml:"max_wait,omitempty"`
+	Mode    string        `yaml:"mode,omitempty"`
+}
+
+// WatcherConfig selects how file changes are detected
+type WatcherConfig struct {
+	Backend      string        `yaml:"backend,omitempty"`
+	PollInterval time.Duration `yaml:"poll_interval,omitempty"`
+}
+
+// StopConfig controls how a command is stopped: stop_command or stop_signal is
+// sent first, and the process group is killed if it is still running after
+// stop_timeout. A stop_timeout of 0 kills it immediately.
+type StopConfig struct {
+	Signal  string         `yaml:"stop_signal,omitempty"`
+	Timeout *time.Duration `yaml:"stop_timeout,omitempty"`
+	Command string         `yaml:"stop_command,omitempty"`
+}
+
+// Route maps requests matching a path prefix and/or host to an upstream backend
+type Route struct {
+	Name        string `yaml:"name"`
+	PathPrefix  string `yaml:"path_prefix,omitempty"`
+	Host        string `yaml:"host,omitempty"`
+	Port        int    `yaml:"port,omitempty"`
+	URL         string `yaml:"url,omitempty"`
+	Socket      string `yaml:"socket,omitempty"`
+	StripPrefix bool   `yaml:"strip_prefix,omitempty"`
+	HealthCheck string `yaml:"health_check,omitempty"`
+}
+
+// StaticMount serves a local directory under a path prefix instead of proxying
+type StaticMount struct {
+	PathPrefix string `yaml:"path_prefix"`
+	Dir        string `yaml:"dir"`
+	SPA        bool   `yaml:"spa,omitempty"`
+}
+
+// InspectorConfig configures recording of proxied requests
+type InspectorConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	AccessLog      bool     `yaml:"access_log"`
+	BufferSize     int      `yaml:"buffer_size"`
+	CaptureHeaders bool     `yaml:"capture_headers"`
+	CaptureBodies  bool     `yaml:"capture_bodies"`
+	MaxBodySize    int      `yaml:"max_body_size"`
+	Redact         []string `yaml:"redact"`
+}
+
+// FaultRule injects latency or failures into matching proxied requests
+type FaultRule struct {
+	Name          string        `yaml:"name" json:"name"`
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	Route         string        `yaml:"route,omitempty" json:"route,omitempty"`
+	PathPrefix    string        `yaml:"path_prefix,omitempty" json:"path_prefix,omitempty"`
+	Methods       []string      `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Latency       time.Duration `yaml:"latency,omitempty" json:"latency,omitempty"`
+	LatencyJitter time.Duration `yaml:"latency_jitter,omitempty" json:"latency_jitter,omitempty"`
+	BandwidthKBps int           `yaml:"bandwidth_kbps,omitempty" json:"bandwidth_kbps,omitempty"`
+	ErrorStatus   int           `yaml:"error_status,omitempty" json:"error_status,omitempty"`
+	ErrorRate     float64       `yaml:"error_rate,omitempty" json:"error_rate,omitempty"`
+	ResetRate     float64       `yaml:"reset_rate,omitempty" json:"reset_rate,omitempty"`
+}
+
+// TLSConfig configures HTTPS for the proxy server using local development certificates
+type TLSConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	CertDir      string   `yaml:"cert_dir,omitempty"`
+	Hostnames    []string `yaml:"hostnames,omitempty"`
+	RedirectPort int      `yaml:"redirect_port,omitempty"`
+}
+
+// RestartPolicy controls whether the app is restarted after it exits on its own
+type RestartPolicy struct {
+	Policy     string        `yaml:"policy"`
+	MaxRetries int           `yaml:"max_retries,omitempty"`
+	Backoff    time.Duration `yaml:"backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+// ReadyCheck decides when a managed process is ready to use
+type ReadyCheck struct {
+	HTTP    string        `yaml:"http,omitempty"`
+	TCP     string        `yaml:"tcp,omitempty"`
+	Log     string        `yaml:"log,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Dependency is a managed process that must reach a condition before another process starts
+type Dependency struct {
+	Process   string        `yaml:"process"`
+	Condition string        `yaml:"condition,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Dependencies is a depends_on list, written as process names or as a mapping of
+// process names to conditions
+type Dependencies []Dependency
+
+// ProcessConfig is a long-running process managed alongside the app
+type ProcessConfig struct {
+	Name           string            `yaml:"name"`
+	Command        string            `yaml:"command"`
+	Env            map[string]string `yaml:"env,omitempty"`
+	Dir            string            `yaml:"dir,omitempty"`
+	RestartPolicy  RestartPolicy     `yaml:"restart_policy,omitempty"`
+	Ready          ReadyCheck        `yaml:"ready,omitempty"`
+	RestartOnBuild bool              `yaml:"restart_on_build,omitempty"`
+	DependsOn      Dependencies      `yaml:"depends_on,omitempty"`
+	Stop           StopConfig        `yaml:",inline"`
+}
+
+// SwapConfig enables zero-downtime blue/green restarts: the rebuilt app starts
+// on the other of backend_port and port, and the proxy switches to it once it is ready
+type SwapConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Port         int           `yaml:"port,omitempty"`
+	ReadyTimeout time.Duration `yaml:"ready_timeout,omitempty"`
+	DrainTimeout time.Duration `yaml:"drain_timeout,omitempty"`
+}
+
+// LogsConfig configures capture of the application's output
+type LogsConfig struct {
+	BufferSize int    `yaml:"buffer_size"`
+	Format     string `yaml:"format,omitempty"`
+	Level      string `yaml:"level,omitempty"`
 }
 
 // Config represents the configuration for the dev server
 type Config struct {
-	ProxyPort      int         `yaml:"proxy_port"`
-	BackendPort    int         `yaml:"backend_port"`
-	BuildStatusDir string      `yaml:"build_status_dir"`
-	BuildRules     []BuildRule `yaml:"build_rules"`
-	RunCmd         string      `yaml:"run_cmd"`
-	InjectScript   bool        `yaml:"inject_script"`
+	ProxyPort       int             `yaml:"proxy_port"`
+	BackendPort     int             `yaml:"backend_port"`
+	BuildStatusDir  string          `yaml:"build_status_dir"`
+	BuildRules      []BuildRule     `yaml:"build_rules"`
+	Debounce        DebounceConfig  `yaml:"debounce"`
+	Watcher         WatcherConfig   `yaml:"watcher,omitempty"`
+	NoCache         bool            `yaml:"-"`
+	Verbose         bool            `yaml:"verbose,omitempty"`
+	RunCmd          string          `yaml:"run_cmd"`
+	StopBeforeBuild bool            `yaml:"stop_before_build"`
+	RestartPolicy   RestartPolicy   `yaml:"restart_policy"`
+	Stop            StopConfig      `yaml:",inline"`
+	Swap            SwapConfig      `yaml:"swap,omitempty"`
+	Processes       []ProcessConfig `yaml:"processes,omitempty"`
+	DependsOn       Dependencies    `yaml:"depends_on,omitempty"`
+	InjectScript    bool            `yaml:"inject_script"`
+	Routes          []Route         `yaml:"routes,omitempty"`
+	Static          []StaticMount   `yaml:"static,omitempty"`
+	Faults          []FaultRule     `yaml:"faults,omitempty"`
+	TLS             TLSConfig       `yaml:"tls"`
+	Inspector       InspectorConfig `yaml:"inspector"`
+	Logs            LogsConfig      `yaml:"logs"`
 }
 
 // DefaultConfig returns a default configuration
@@ -42,8 +195,27 @@ func DefaultConfig() *Config {
 				Command: "go build -o ./tmp/main .",
 			},
 		},
-		RunCmd:       "./tmp/main",
-		InjectScript: true,
+		Debounce: DebounceConfig{
+			Delay: defaultDebounceDelay,
+		},
+		RunCmd:          "./tmp/main",
+		StopBeforeBuild: true,
+		InjectScript:    true,
+		RestartPolicy: RestartPolicy{
+			Policy:     RestartNever,
+			MaxRetries: defaultMaxRetries,
+			Backoff:    500 * time.Millisecond,
+			MaxBackoff: 30 * time.Second,
+		},
+		Inspector: InspectorConfig{
+			Enabled:     true,
+			BufferSize:  200,
+			MaxBodySize: 64 * 1024,
+			Redact:      []string{"Authorization", "Cookie", "Set-Cookie", "password", "token"},
+		},
+		Logs: LogsConfig{
+			BufferSize: 1000,
+		},
 	}
 }
 
@@ -0,0 +1,175 @@
+package godevwatch
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// clientScriptPath is the reserved path the live reload client is served from
+const clientScriptPath = "/.godevwatch-client.js"
+
+// cspHeaders are the response headers that may carry a content security policy
+var cspHeaders = []string{
+	"Content-Security-Policy",
+	"Content-Security-Policy-Report-Only",
+}
+
+// scriptNonceRe matches the nonce attribute of a script tag already present in the page
+var scriptNonceRe = regexp.MustCompile(`(?i)<script[^>]*\snonce=["']?([^"'\s>]+)`)
+
+// cspDirective is a single directive of a content security policy
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// parseCSP splits a policy into its directives, preserving order
+func parseCSP(policy string) []cspDirective {
+	var directives []cspDirective
+	for _, part := range strings.Split(policy, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		directives = append(directives, cspDirective{
+			name:    strings.ToLower(fields[0]),
+			sources: fields[1:],
+		})
+	}
+	return directives
+}
+
+// formatCSP joins directives back into a policy string
+func formatCSP(directives []cspDirective) string {
+	parts := make([]string, 0, len(directives))
+	for _, d := range directives {
+		parts = append(parts, strings.TrimSpace(d.name+" "+strings.Join(d.sources, " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// findDirective returns the index of the first directive in names that is present, or -1
+func findDirective(directives []cspDirective, names ...string) int {
+	for _, name := range names {
+		for i, d := range directives {
+			if d.name == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// hasSource checks whether a directive contains the given source expression
+func (d cspDirective) hasSource(source string) bool {
+	for _, s := range d.sources {
+		if strings.EqualFold(s, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonce returns the first nonce listed in the directive, if any
+func (d cspDirective) nonce() string {
+	for _, s := range d.sources {
+		if strings.HasPrefix(s, "'nonce-") && strings.HasSuffix(s, "'") {
+			return strings.TrimSuffix(strings.TrimPrefix(s, "'nonce-"), "'")
+		}
+	}
+	return ""
+}
+
+// allowSources adds sources to the directive that governs fetchName. When the
+// policy only falls back to default-src, a dedicated directive is created from
+// a copy of default-src so other resource types keep their original policy.
+func allowSources(directives []cspDirective, fetchName string, lookup []string, sources ...string) []cspDirective {
+	i := findDirective(directives, lookup...)
+	if i == -1 {
+		// Resource type is unrestricted
+		return directives
+	}
+
+	if directives[i].name == "default-src" {
+		copied := append([]string{}, directives[i].sources...)
+		directives = append(directives, cspDirective{name: fetchName, sources: copied})
+		i = len(directives) - 1
+	}
+
+	// 'none' cannot be combined with other sources
+	if directives[i].hasSource("'none'") {
+		directives[i].sources = nil
+	}
+
+	for _, source := range sources {
+		if !directives[i].hasSource(source) {
+			directives[i].sources = append(directives[i].sources, source)
+		}
+	}
+
+	return directives
+}
+
+// rewriteCSP updates a policy so the injected client script may load and open
+// its WebSocket. It returns the rewritten policy and the nonce the script tag
+// must carry, which is empty when no nonce is needed.
+func rewriteCSP(policy, host, nonce string) (string, string) {
+	directives := parseCSP(policy)
+	scriptLookup := []string{"script-src-elem", "script-src", "default-src"}
+
+	if i := findDirective(directives, scriptLookup...); i != -1 {
+		d := directives[i]
+		switch {
+		case nonce != "" && d.hasSource("'nonce-"+nonce+"'"):
+			// Page nonce is already allowed
+		case d.nonce() != "":
+			nonce = d.nonce()
+		case d.hasSource("'strict-dynamic'"):
+			// Host sources are ignored under strict-dynamic, so a nonce is required
+			if nonce == "" {
+				nonce = generateNonce()
+			}
+			directives = allowSources(directives, "script-src", scriptLookup, "'nonce-"+nonce+"'")
+		default:
+			// Adding a nonce would disable 'unsafe-inline' for the page, so allow by source
+			directives = allowSources(directives, "script-src", scriptLookup, "'self'")
+		}
+	}
+
+	directives = allowSources(directives, "connect-src", []string{"connect-src", "default-src"},
+		"'self'", "ws://"+host, "wss://"+host)
+
+	return formatCSP(directives), nonce
+}
+
+// applyCSP rewrites the policy headers of a response and returns the nonce the
+// injected script tag should use. body is searched for an existing nonce so a
+// policy delivered via a meta tag keeps working.
+func applyCSP(header http.Header, host string, body string) string {
+	nonce := ""
+	if m := scriptNonceRe.FindStringSubmatch(body); m != nil {
+		nonce = m[1]
+	}
+
+	for _, name := range cspHeaders {
+		policies, ok := header[name]
+		if !ok {
+			continue
+		}
+		for i, policy := range policies {
+			policies[i], nonce = rewriteCSP(policy, host, nonce)
+		}
+	}
+
+	return nonce
+}
+
+// generateNonce returns a random base64 nonce
+func generateNonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
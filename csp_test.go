@@ -0,0 +1,106 @@
+package godevwatch
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRewriteCSP(t *testing.T) {
+	const connect = "connect-src 'self' ws://localhost:3000 wss://localhost:3000"
+
+	tests := []struct {
+		name      string
+		policy    string
+		pageNonce string
+		want      string
+		wantNonce string
+	}{
+		{"no policy", "", "", "", ""},
+		{"unrelated directives", "img-src 'self'", "", "img-src 'self'", ""},
+		{"default-src only", "default-src 'self'", "", "default-src 'self'; script-src 'self'; " + connect, ""},
+		{"default-src none", "Default-Src 'none'", "", "default-src 'none'; script-src 'self'; " + connect, ""},
+		{"host sources", "script-src https://cdn.example.com; connect-src 'none'", "",
+			"script-src https://cdn.example.com 'self'; " + connect, ""},
+		{"script-src-elem wins", "script-src-elem 'self'; script-src 'none'", "", "script-src-elem 'self'; script-src 'none'", ""},
+		{"existing nonce reused", "script-src 'nonce-abc'", "", "script-src 'nonce-abc'", "abc"},
+		{"page nonce already allowed", "script-src 'nonce-abc' 'nonce-xyz'", "xyz", "script-src 'nonce-abc' 'nonce-xyz'", "xyz"},
+		{"strict-dynamic with page nonce", "script-src 'strict-dynamic'", "xyz", "script-src 'strict-dynamic' 'nonce-xyz'", "xyz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, nonce := rewriteCSP(tt.policy, "localhost:3000", tt.pageNonce)
+			if policy != tt.want {
+				t.Errorf("policy = %q, want %q", policy, tt.want)
+			}
+			if nonce != tt.wantNonce {
+				t.Errorf("nonce = %q, want %q", nonce, tt.wantNonce)
+			}
+		})
+	}
+}
+
+func TestRewriteCSPGeneratesNonce(t *testing.T) {
+	policy, nonce := rewriteCSP("script-src 'strict-dynamic'", "localhost:3000", "")
+	if nonce == "" {
+		t.Fatal("no nonce generated under strict-dynamic")
+	}
+	if want := "script-src 'strict-dynamic' 'nonce-" + nonce + "'"; policy != want {
+		t.Fatalf("policy = %q, want %q", policy, want)
+	}
+}
+
+func TestApplyCSP(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    http.Header
+		body      string
+		want      http.Header
+		wantNonce string
+	}{
+		{
+			name:   "no policy",
+			header: http.Header{},
+			body:   "<html></html>",
+			want:   http.Header{},
+		},
+		{
+			name:      "meta tag policy keeps the page nonce",
+			header:    http.Header{},
+			body:      `<script nonce="abc">init()</script>`,
+			want:      http.Header{},
+			wantNonce: "abc",
+		},
+		{
+			name: "enforced and report-only policies",
+			header: http.Header{
+				"Content-Security-Policy":             {"script-src 'strict-dynamic'"},
+				"Content-Security-Policy-Report-Only": {"script-src 'self'"},
+			},
+			body: `<SCRIPT type="module" nonce=abc>`,
+			want: http.Header{
+				"Content-Security-Policy":             {"script-src 'strict-dynamic' 'nonce-abc'"},
+				"Content-Security-Policy-Report-Only": {"script-src 'self'"},
+			},
+			wantNonce: "abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce := applyCSP(tt.header, "localhost:3000", tt.body)
+			if nonce != tt.wantNonce {
+				t.Errorf("nonce = %q, want %q", nonce, tt.wantNonce)
+			}
+			for name, values := range tt.want {
+				if got := strings.Join(tt.header[name], ", "); got != strings.Join(values, ", ") {
+					t.Errorf("%s = %q, want %q", name, got, values)
+				}
+			}
+			if len(tt.header) != len(tt.want) {
+				t.Errorf("headers = %v, want %v", tt.header, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package godevwatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBuildHistory is how many finished builds the dashboard remembers
+const maxBuildHistory = 20
+
+// RuleTiming is how long a single build rule took
+type RuleTiming struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// BuildRecord is a build as shown on the dashboard
+type BuildRecord struct {
+	ID           string       `json:"id"`
+	Status       string       `json:"status"`
+	Started      time.Time    `json:"started"`
+	DurationMs   float64      `json:"duration_ms,omitempty"`
+	ChangedFiles []string     `json:"changed_files,omitempty"`
+	Rules        []RuleTiming `json:"rules"`
+	Error        string       `json:"error,omitempty"`
+
+	ruleStarted time.Time
+}
+
+// AppState is the state of the application or another managed process
+type AppState struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	BuildID  string        `json:"build_id,omitempty"`
+	PID      int           `json:"pid,omitempty"`
+	Command  string        `json:"command,omitempty"`
+	Started  time.Time     `json:"started"`
+	Exited   time.Time     `json:"exited"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`
+	Crash    *AppCrashData `json:"crash,omitempty"`
+}
+
+// DashboardState is everything the dashboard shows on load
+type DashboardState struct {
+	Watching  bool              `json:"watching"`
+	Watcher   WatcherStatusData `json:"watcher"`
+	Builds    []BuildRecord     `json:"builds"`
+	App       AppState          `json:"app"`
+	Processes []AppState        `json:"processes"`
+	Backends  []BackendStatus   `json:"backends"`
+}
+
+// Dashboard keeps the build history and app state built from lifecycle events
+type Dashboard struct {
+	builds    []*BuildRecord
+	processes []*AppState
+	mu        sync.Mutex
+}
+
+// NewDashboard creates a new dashboard
+func NewDashboard() *Dashboard {
+	return &Dashboard{
+		processes: []*AppState{{Name: appProcessName, Status: "stopped"}},
+	}
+}
+
+// Handle updates the dashboard state from an event
+func (d *Dashboard) Handle(event Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	switch data := event.Data.(type) {
+	case BuildStartedData:
+		d.builds = append([]*BuildRecord{{
+			ID:           data.BuildID,
+			Status:       string(BuildStatusBuilding),
+			Started:      now,
+			ChangedFiles: data.ChangedFiles,
+			Rules:        []RuleTiming{},
+		}}, d.builds...)
+		if len(d.builds) > maxBuildHistory {
+			d.builds = d.builds[:maxBuildHistory]
+		}
+
+	case RuleStartedData:
+		if build := d.find(data.BuildID); build != nil {
+			build.finishRule(now, "succeeded")
+			build.Rules = append(build.Rules, RuleTiming{Name: data.Rule, Status: "running"})
+			build.ruleStarted = now
+		}
+
+	case BuildFinishedData:
+		if build := d.find(data.BuildID); build != nil {
+			build.finishRule(now, data.Status)
+			build.Status = data.Status
+			build.DurationMs = data.DurationMs
+			build.Error = data.Error
+		}
+
+	case AppStartedData:
+		*d.process(data.Process) = AppState{
+			Name:    data.Process,
+			Status:  "running",
+			BuildID: data.BuildID,
+			PID:     data.PID,
+			Command: data.Command,
+			Started: now,
+		}
+
+	case AppExitedData:
+		if process := d.process(data.Process); data.PID == process.PID {
+			process.Status = "exited"
+			process.Exited = now
+			process.ExitCode = data.ExitCode
+			process.Error = data.Error
+		}
+
+	case AppCrashData:
+		if process := d.process(data.Process); data.PID == process.PID {
+			process.Status = "crashed"
+			process.Crash = &data
+		}
+	}
+}
+
+// process returns the state of the named process, adding it in the order
+// first seen, the caller must hold mu
+func (d *Dashboard) process(name string) *AppState {
+	for _, process := range d.processes {
+		if process.Name == name {
+			return process
+		}
+	}
+	process := &AppState{Name: name, Status: "stopped"}
+	d.processes = append(d.processes, process)
+	return process
+}
+
+// LastCrash returns the crash that stopped the app, or nil while it is running
+func (d *Dashboard) LastCrash() *AppCrashData {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.process(appProcessName).Crash
+}
+
+// find returns the build with the given ID, the caller must hold mu
+func (d *Dashboard) find(buildID string) *BuildRecord {
+	for _, build := range d.builds {
+		if build.ID == buildID {
+			return build
+		}
+	}
+	return nil
+}
+
+// finishRule records the duration of the rule that is still running
+func (b *BuildRecord) finishRule(now time.Time, status string) {
+	if len(b.Rules) == 0 {
+		return
+	}
+	rule := &b.Rules[len(b.Rules)-1]
+	if rule.Status != "running" {
+		return
+	}
+	rule.Status = status
+	rule.DurationMs = float64(now.Sub(b.ruleStarted).Microseconds()) / 1000
+}
+
+// State returns a snapshot of the build history and process states
+func (d *Dashboard) State() DashboardState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	builds := make([]BuildRecord, 0, len(d.builds))
+	for _, build := range d.builds {
+		b := *build
+		b.Rules = append([]RuleTiming{}, build.Rules...)
+		builds = append(builds, b)
+	}
+
+	processes := make([]AppState, 0, len(d.processes)-1)
+	for _, process := range d.processes[1:] {
+		processes = append(processes, *process)
+	}
+
+	return DashboardState{
+		Builds:    builds,
+		App:       *d.processes[0],
+		Processes: processes,
+	}
+}
+
+// SetFileWatcher connects the watcher so the dashboard can rebuild, restart and pause
+func (ps *ProxyServer) SetFileWatcher(watcher *FileWatcher) {
+	ps.fileWatcher = watcher
+	watcher.SetUpstream(ps)
+}
+
+// handleDashboard serves the dashboard page
+func (ps *ProxyServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(dashboardHTML)
+}
+
+// handleDashboardState returns the dashboard state as JSON
+func (ps *ProxyServer) handleDashboardState(w http.ResponseWriter, r *http.Request) {
+	state := ps.dashboard.State()
+	state.Backends = ps.backendStatuses()
+	if ps.fileWatcher != nil {
+		state.Watching = true
+		state.Watcher = ps.fileWatcher.Status()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// handleDashboardAction runs a rebuild, restart, pause or resume requested from the dashboard
+func (ps *ProxyServer) handleDashboardAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ps.fileWatcher == nil {
+		http.Error(w, "File watching is disabled", http.StatusConflict)
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, "/.godevwatch/actions/") {
+	case "rebuild":
+		ps.fileWatcher.Rebuild()
+	case "restart":
+		ps.fileWatcher.RestartApp()
+	case "pause":
+		ps.fileWatcher.SetPaused(true)
+	case "resume":
+		ps.fileWatcher.SetPaused(false)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,204 @@
+package godevwatch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDashboardBuilds(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []interface{}
+		wantStatus string
+		wantRules  string
+	}{
+		{"building", []interface{}{
+			BuildStartedData{BuildID: "b1", ChangedFiles: []string{"main.go"}},
+			RuleStartedData{BuildID: "b1", Rule: "templ"},
+		}, "building", "[templ:running]"},
+		{"succeeded", []interface{}{
+			BuildStartedData{BuildID: "b1"},
+			RuleStartedData{BuildID: "b1", Rule: "templ"},
+			RuleStartedData{BuildID: "b1", Rule: "go"},
+			BuildFinishedData{BuildID: "b1", Status: "succeeded", DurationMs: 12},
+		}, "succeeded", "[templ:succeeded go:succeeded]"},
+		{"failed rule", []interface{}{
+			BuildStartedData{BuildID: "b1"},
+			RuleStartedData{BuildID: "b1", Rule: "templ"},
+			RuleStartedData{BuildID: "b1", Rule: "go"},
+			BuildFinishedData{BuildID: "b1", Status: "failed", Rule: "go", Error: "exit status 1"},
+		}, "failed", "[templ:succeeded go:failed]"},
+		{"unknown build ignored", []interface{}{
+			BuildStartedData{BuildID: "b1"},
+			BuildFinishedData{BuildID: "other", Status: "failed"},
+		}, "building", "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDashboard()
+			for _, data := range tt.events {
+				d.Handle(Event{Data: data})
+			}
+
+			builds := d.State().Builds
+			if len(builds) != 1 {
+				t.Fatalf("builds = %+v", builds)
+			}
+			var rules []string
+			for _, rule := range builds[0].Rules {
+				rules = append(rules, rule.Name+":"+rule.Status)
+			}
+			if builds[0].Status != tt.wantStatus || fmt.Sprint(rules) != tt.wantRules {
+				t.Fatalf("build = %s %v, want %s %s", builds[0].Status, rules, tt.wantStatus, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestDashboardBuildHistory(t *testing.T) {
+	d := NewDashboard()
+	for i := 0; i < maxBuildHistory+5; i++ {
+		d.Handle(Event{Data: BuildStartedData{BuildID: fmt.Sprint(i)}})
+	}
+
+	builds := d.State().Builds
+	if len(builds) != maxBuildHistory {
+		t.Fatalf("kept %d builds, want %d", len(builds), maxBuildHistory)
+	}
+	if builds[0].ID != fmt.Sprint(maxBuildHistory+4) || builds[len(builds)-1].ID != "5" {
+		t.Fatalf("builds run from %s to %s", builds[0].ID, builds[len(builds)-1].ID)
+	}
+}
+
+func TestDashboardProcesses(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []interface{}
+		process    string
+		wantStatus string
+		wantCrash  bool
+	}{
+		{"app stopped", nil, "app", "stopped", false},
+		{"app running", []interface{}{
+			AppStartedData{Process: "app", PID: 10},
+		}, "app", "running", false},
+		{"app exited", []interface{}{
+			AppStartedData{Process: "app", PID: 10},
+			AppExitedData{Process: "app", PID: 10, ExitCode: 1},
+		}, "app", "exited", false},
+		{"app crashed", []interface{}{
+			AppStartedData{Process: "app", PID: 10},
+			AppExitedData{Process: "app", PID: 10, ExitCode: 2},
+			AppCrashData{Process: "app", PID: 10, ExitCode: 2},
+		}, "app", "crashed", true},
+		{"crash cleared by restart", []interface{}{
+			AppStartedData{Process: "app", PID: 10},
+			AppCrashData{Process: "app", PID: 10},
+			AppStartedData{Process: "app", PID: 11},
+		}, "app", "running", false},
+		{"exit of replaced process ignored", []interface{}{
+			AppStartedData{Process: "app", PID: 10},
+			AppStartedData{Process: "app", PID: 11},
+			AppExitedData{Process: "app", PID: 10},
+		}, "app", "running", false},
+		{"other process", []interface{}{
+			AppStartedData{Process: "worker", PID: 20},
+		}, "worker", "running", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDashboard()
+			for _, data := range tt.events {
+				d.Handle(Event{Data: data})
+			}
+
+			state := d.State()
+			process := state.App
+			if tt.process != appProcessName {
+				if len(state.Processes) != 1 {
+					t.Fatalf("processes = %+v", state.Processes)
+				}
+				process = state.Processes[0]
+			}
+			if process.Name != tt.process || process.Status != tt.wantStatus {
+				t.Fatalf("process = %+v, want %s %s", process, tt.process, tt.wantStatus)
+			}
+			if (d.LastCrash() != nil) != tt.wantCrash {
+				t.Fatalf("last crash = %+v", d.LastCrash())
+			}
+		})
+	}
+}
+
+func TestDashboardAction(t *testing.T) {
+	fw, _ := newTestWatcher(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		action     string
+		watcher    *FileWatcher
+		wantStatus int
+	}{
+		{"pause", http.MethodPost, "pause", fw, http.StatusAccepted},
+		{"resume", http.MethodPost, "resume", fw, http.StatusAccepted},
+		{"unknown action", http.MethodPost, "deploy", fw, http.StatusNotFound},
+		{"get", http.MethodGet, "pause", fw, http.StatusMethodNotAllowed},
+		{"watching disabled", http.MethodPost, "pause", nil, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := &ProxyServer{fileWatcher: tt.watcher}
+			w := httptest.NewRecorder()
+			ps.handleDashboardAction(w, httptest.NewRequest(tt.method, "/.godevwatch/actions/"+tt.action, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if fw.Status().Paused {
+		t.Fatal("watcher still paused after resume")
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"same origin fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://localhost:3000"}, http.StatusNoContent},
+		{"cross site fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "http://evil.example"}, http.StatusForbidden},
+		{"same site fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "http://localhost:5173"}, http.StatusForbidden},
+		{"matching origin", http.MethodDelete, map[string]string{"Origin": "http://localhost:3000"}, http.StatusNoContent},
+		{"other origin", http.MethodDelete, map[string]string{"Origin": "http://localhost:5173"}, http.StatusForbidden},
+		{"opaque origin", http.MethodPost, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"no origin headers", http.MethodPost, nil, http.StatusNoContent},
+		{"cross site read", http.MethodGet, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusNoContent},
+	}
+
+	handler := sameOrigin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://localhost:3000/.godevwatch/actions/rebuild", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package godevwatch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DebounceTrailing builds once changes have stopped for the debounce delay
+	DebounceTrailing = "trailing"
+
+	// DebounceLeading builds on the first change, then once more for the changes that follow it
+	DebounceLeading = "leading"
+
+	// defaultDebounceDelay is how long changes must stop before a build without a configured delay
+	defaultDebounceDelay = 100 * time.Millisecond
+)
+
+// UnmarshalYAML accepts either a delay or a full debounce configuration
+func (dc *DebounceConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&dc.Delay)
+	}
+
+	type plain DebounceConfig
+	return value.Decode((*plain)(dc))
+}
+
+// Validate checks the debounce mode and durations
+func (dc DebounceConfig) Validate() error {
+	switch dc.Mode {
+	case "", DebounceTrailing, DebounceLeading:
+	default:
+		return fmt.Errorf("invalid debounce mode %q, expected %q or %q", dc.Mode, DebounceTrailing, DebounceLeading)
+	}
+	if dc.Delay < 0 || dc.MaxWait < 0 {
+		return fmt.Errorf("debounce delay and max_wait cannot be negative")
+	}
+	return nil
+}
+
+// debounceFor returns the debounce settings for a changed file. A file matching
+// several rules uses the longest delay, the shortest max_wait and leading mode
+// if any of the rules uses it. Settings a rule leaves out come from the global ones.
+func (fw *FileWatcher) debounceFor(path string) DebounceConfig {
+	global := fw.config.Debounce
+	var result DebounceConfig
+	matched := false
+
+	for _, rule := range fw.config.BuildRules {
+		if !fw.ruleWatches(rule, path) {
+			continue
+		}
+
+		dc := rule.Debounce
+		if dc.Delay == 0 {
+			dc.Delay = global.Delay
+		}
+		if dc.MaxWait == 0 {
+			dc.MaxWait = global.MaxWait
+		}
+		if dc.Mode == "" {
+			dc.Mode = global.Mode
+		}
+
+		if !matched {
+			result = dc
+			matched = true
+			continue
+		}
+		result = result.merge(dc)
+	}
+	if !matched {
+		result = global
+	}
+
+	if result.Delay == 0 {
+		result.Delay = defaultDebounceDelay
+	}
+	if result.Mode == "" {
+		result.Mode = DebounceTrailing
+	}
+	return result
+}
+
+// merge combines the settings of two rules matching the same changes
+func (dc DebounceConfig) merge(other DebounceConfig) DebounceConfig {
+	dc.Delay = max(dc.Delay, other.Delay)
+	if other.MaxWait > 0 && (dc.MaxWait == 0 || other.MaxWait < dc.MaxWait) {
+		dc.MaxWait = other.MaxWait
+	}
+	if other.Mode == DebounceLeading {
+		dc.Mode = DebounceLeading
+	}
+	return dc
+}
+
+// debouncer groups bursts of file changes into builds. A burst ends once no
+// changes arrive for the debounce delay, or when max_wait has passed since its
+// first change.
+type debouncer struct {
+	trigger  func(files []string)
+	files    []string
+	active   bool
+	settings DebounceConfig
+	first    time.Time
+	timer    *time.Timer
+	gen      int
+	mu       sync.Mutex
+}
+
+// newDebouncer creates a debouncer that passes each burst of changed files to trigger
+func newDebouncer(trigger func(files []string)) *debouncer {
+	return &debouncer{trigger: trigger}
+}
+
+// Add records a changed file. In leading mode the first change of a burst is
+// passed on immediately and the rest of the burst is built afterwards.
+func (d *debouncer) Add(path string, settings DebounceConfig) {
+	d.mu.Lock()
+	now := time.Now()
+
+	if !d.active {
+		d.active = true
+		d.first = now
+		d.settings = settings
+		if settings.Mode == DebounceLeading {
+			d.schedule(settings.Delay)
+			d.mu.Unlock()
+			// Like the trailing trigger, run off the caller's goroutine so a
+			// slow trigger cannot hold up file events
+			go d.trigger([]string{path})
+			return
+		}
+	} else {
+		d.settings = d.settings.merge(settings)
+	}
+	d.files = append(d.files, path)
+
+	wait := d.settings.Delay
+	if d.settings.MaxWait > 0 {
+		wait = max(min(wait, d.settings.MaxWait-now.Sub(d.first)), 0)
+	}
+	d.schedule(wait)
+	d.mu.Unlock()
+}
+
+// schedule replaces the pending timer, the caller must hold mu
+func (d *debouncer) schedule(wait time.Duration) {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.gen++
+	gen := d.gen
+	d.timer = time.AfterFunc(wait, func() { d.fire(gen) })
+}
+
+// fire ends the burst and triggers a build for its changes, unless a later
+// change rescheduled it
+func (d *debouncer) fire(gen int) {
+	d.mu.Lock()
+	if gen != d.gen {
+		d.mu.Unlock()
+		return
+	}
+	files := d.files
+	d.files = nil
+	d.active = false
+	d.mu.Unlock()
+
+	if len(files) > 0 {
+		d.trigger(files)
+	}
+}
+
+// Stop cancels any pending build
+func (d *debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.gen++
+}
@@ -0,0 +1,171 @@
+package godevwatch
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDebounceConfigUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want DebounceConfig
+	}{
+		{"delay", "250ms", DebounceConfig{Delay: 250 * time.Millisecond}},
+		{"full config", "delay: 1s\nmax_wait: 5s\nmode: leading", DebounceConfig{Delay: time.Second, MaxWait: 5 * time.Second, Mode: DebounceLeading}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dc DebounceConfig
+			if err := yaml.Unmarshal([]byte(tt.yaml), &dc); err != nil {
+				t.Fatal(err)
+			}
+			if dc != tt.want {
+				t.Fatalf("debounce = %+v, want %+v", dc, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebounceConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  DebounceConfig
+		wantErr string
+	}{
+		{"defaults", DebounceConfig{}, ""},
+		{"leading", DebounceConfig{Mode: DebounceLeading, Delay: time.Second, MaxWait: time.Minute}, ""},
+		{"unknown mode", DebounceConfig{Mode: "eager"}, `invalid debounce mode "eager"`},
+		{"negative delay", DebounceConfig{Delay: -time.Second}, "cannot be negative"},
+		{"negative max wait", DebounceConfig{MaxWait: -time.Second}, "cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDebounceFor(t *testing.T) {
+	fw, dir := newTestWatcher(t,
+		BuildRule{Name: "go", Watch: []string{"*.go"}, Command: "true", Debounce: DebounceConfig{Delay: 500 * time.Millisecond, MaxWait: 5 * time.Second}},
+		BuildRule{Name: "templ", Watch: []string{"*.templ", "*.go"}, Command: "true", Debounce: DebounceConfig{MaxWait: 2 * time.Second, Mode: DebounceLeading}},
+		BuildRule{Name: "css", Watch: []string{"*.css"}, Command: "true"},
+	)
+	fw.config.Debounce = DebounceConfig{Delay: 200 * time.Millisecond}
+
+	tests := []struct {
+		file string
+		want DebounceConfig
+	}{
+		{"main.go", DebounceConfig{Delay: 500 * time.Millisecond, MaxWait: 2 * time.Second, Mode: DebounceLeading}},
+		{"page.templ", DebounceConfig{Delay: 200 * time.Millisecond, MaxWait: 2 * time.Second, Mode: DebounceLeading}},
+		{"app.css", DebounceConfig{Delay: 200 * time.Millisecond, Mode: DebounceTrailing}},
+		{"README.md", DebounceConfig{Delay: 200 * time.Millisecond, Mode: DebounceTrailing}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := fw.debounceFor(filepath.Join(dir, tt.file)); got != tt.want {
+				t.Fatalf("debounceFor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Without any configuration the default delay applies
+	fw.config.Debounce = DebounceConfig{}
+	if got := fw.debounceFor(filepath.Join(dir, "app.css")); got.Delay != defaultDebounceDelay {
+		t.Fatalf("default delay = %s", got.Delay)
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings DebounceConfig
+		changes  int
+		interval time.Duration
+		want     string
+	}{
+		{"trailing", DebounceConfig{Delay: 100 * time.Millisecond, Mode: DebounceTrailing}, 3, 20 * time.Millisecond, "[[0 1 2]]"},
+		{"leading", DebounceConfig{Delay: 100 * time.Millisecond, Mode: DebounceLeading}, 3, 20 * time.Millisecond, "[[0] [1 2]]"},
+		{"leading single change", DebounceConfig{Delay: 100 * time.Millisecond, Mode: DebounceLeading}, 1, 0, "[[0]]"},
+		{"separate bursts", DebounceConfig{Delay: 50 * time.Millisecond, Mode: DebounceTrailing}, 2, 200 * time.Millisecond, "[[0] [1]]"},
+		{"max wait", DebounceConfig{Delay: time.Second, MaxWait: 500 * time.Millisecond, Mode: DebounceTrailing}, 4, 200 * time.Millisecond, "[[0 1 2] [3]]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var builds [][]string
+			d := newDebouncer(func(files []string) {
+				mu.Lock()
+				builds = append(builds, files)
+				mu.Unlock()
+			})
+			defer d.Stop()
+
+			for i := 0; i < tt.changes; i++ {
+				if i > 0 {
+					time.Sleep(tt.interval)
+				}
+				d.Add(fmt.Sprint(i), tt.settings)
+			}
+			time.Sleep(tt.settings.Delay + 300*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got := fmt.Sprint(builds); got != tt.want {
+				t.Fatalf("builds = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebouncerLeadingDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	triggered := make(chan []string, 1)
+	d := newDebouncer(func(files []string) {
+		triggered <- files
+		<-release
+	})
+	defer d.Stop()
+	defer close(release)
+
+	added := make(chan struct{})
+	go func() {
+		d.Add("main.go", DebounceConfig{Delay: time.Second, Mode: DebounceLeading})
+		close(added)
+	}()
+
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("Add blocked on the leading trigger")
+	}
+	select {
+	case files := <-triggered:
+		if fmt.Sprint(files) != "[main.go]" {
+			t.Fatalf("triggered %v", files)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("leading change not triggered")
+	}
+}
@@ -0,0 +1,150 @@
+package godevwatch
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConditionStarted waits for the dependency's process to start
+	ConditionStarted = "started"
+
+	// ConditionHealthy waits for the dependency's ready check to pass
+	ConditionHealthy = "healthy"
+
+	// ConditionCompletedSuccessfully waits for the dependency to exit with code 0
+	ConditionCompletedSuccessfully = "completed_successfully"
+
+	// defaultDependencyTimeout is how long to wait for a dependency without a timeout
+	defaultDependencyTimeout = 60 * time.Second
+)
+
+// UnmarshalYAML accepts a list of process names or dependencies, or a mapping
+// of process names to their condition and timeout
+func (d *Dependencies) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			var dep Dependency
+			if item.Kind == yaml.ScalarNode {
+				dep.Process = item.Value
+			} else if err := item.Decode(&dep); err != nil {
+				return err
+			}
+			*d = append(*d, dep)
+		}
+		return nil
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			dep := Dependency{Process: value.Content[i].Value}
+			if err := value.Content[i+1].Decode(&dep); err != nil {
+				return err
+			}
+			dep.Process = value.Content[i].Value
+			*d = append(*d, dep)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("depends_on must be a list or a mapping of process names")
+}
+
+// validateDependencies checks the depends_on entries of every process and returns
+// the processes in an order that starts each one after its dependencies, keeping
+// the configured order otherwise
+func validateDependencies(processes []ProcessConfig, appDeps Dependencies) ([]ProcessConfig, error) {
+	byName := make(map[string]*ProcessConfig, len(processes))
+	for i := range processes {
+		byName[processes[i].Name] = &processes[i]
+	}
+
+	check := func(owner string, deps Dependencies) error {
+		for i := range deps {
+			dep := &deps[i]
+			target, ok := byName[dep.Process]
+			if !ok {
+				return fmt.Errorf("%s: depends_on unknown process %q", owner, dep.Process)
+			}
+			if dep.Process == owner {
+				return fmt.Errorf("%s: cannot depend on itself", owner)
+			}
+
+			switch dep.Condition {
+			case "":
+				dep.Condition = ConditionStarted
+			case ConditionStarted, ConditionCompletedSuccessfully:
+			case ConditionHealthy:
+				ready := target.Ready
+				if ready.HTTP == "" && ready.TCP == "" && ready.Log == "" {
+					return fmt.Errorf("%s: depends_on %q with condition %q but %q has no ready check", owner, dep.Process, dep.Condition, dep.Process)
+				}
+			default:
+				return fmt.Errorf("%s: invalid depends_on condition %q for %q, expected %q, %q or %q", owner, dep.Condition, dep.Process, ConditionStarted, ConditionHealthy, ConditionCompletedSuccessfully)
+			}
+		}
+		return nil
+	}
+
+	if err := check(appProcessName, appDeps); err != nil {
+		return nil, err
+	}
+	for i := range processes {
+		if err := check(processes[i].Name, processes[i].DependsOn); err != nil {
+			return nil, err
+		}
+	}
+
+	// Depth-first ordering, reporting the path of any cycle
+	ordered := make([]ProcessConfig, 0, len(processes))
+	done := make(map[string]bool, len(processes))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if done[name] {
+			return nil
+		}
+		for i, seen := range path {
+			if seen == name {
+				return fmt.Errorf("depends_on cycle: %s", strings.Join(append(path[i:], name), " -> "))
+			}
+		}
+		process := byName[name]
+		for _, dep := range process.DependsOn {
+			if err := visit(dep.Process, append(path, name)); err != nil {
+				return err
+			}
+		}
+		done[name] = true
+		ordered = append(ordered, *process)
+		return nil
+	}
+	for _, process := range processes {
+		if err := visit(process.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// waitDependencies waits for each dependency to reach its condition, returning
+// an error naming the first one that does not
+func (pm *ProcessManager) waitDependencies(owner string, deps Dependencies, buildID string) error {
+	for _, dep := range deps {
+		target := pm.service(dep.Process)
+		timeout := dep.Timeout
+		if timeout <= 0 {
+			timeout = defaultDependencyTimeout
+		}
+
+		log.Printf("\033[36m[%s] %s waiting for %s to be %s...\033[0m\n", buildID, owner, dep.Process, strings.ReplaceAll(dep.Condition, "_", " "))
+		if err := target.WaitFor(dep.Condition, timeout); err != nil {
+			return fmt.Errorf("dependency %s of %s not met: %w", dep.Process, owner, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package godevwatch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDependenciesUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{"names", "[db, cache]", "[{db  0s} {cache  0s}]", ""},
+		{"list of dependencies", "- db\n- process: migrate\n  condition: completed_successfully", "[{db  0s} {migrate completed_successfully 0s}]", ""},
+		{"mapping", "db:\n  condition: healthy\n  timeout: 5s\ncache: {}", "[{db healthy 5s} {cache  0s}]", ""},
+		{"scalar", "db", "", "depends_on must be a list or a mapping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deps Dependencies
+			err := yaml.Unmarshal([]byte(tt.yaml), &deps)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(deps); got != tt.want {
+				t.Fatalf("dependencies = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	db := ProcessConfig{Name: "db", Ready: ReadyCheck{TCP: "localhost:5432"}}
+	cache := ProcessConfig{Name: "cache"}
+
+	tests := []struct {
+		name      string
+		processes []ProcessConfig
+		app       Dependencies
+		wantOrder string
+		wantErr   string
+	}{
+		{"no dependencies", []ProcessConfig{db, cache}, nil, "[db cache]", ""},
+		{"dependency started first", []ProcessConfig{
+			{Name: "worker", DependsOn: Dependencies{{Process: "migrate"}}},
+			{Name: "migrate", DependsOn: Dependencies{{Process: "db", Condition: ConditionHealthy}}},
+			db,
+		}, nil, "[db migrate worker]", ""},
+		{"app dependency", []ProcessConfig{db}, Dependencies{{Process: "db", Condition: ConditionHealthy}}, "[db]", ""},
+		{"unknown process", []ProcessConfig{db}, Dependencies{{Process: "redis"}}, "", `app: depends_on unknown process "redis"`},
+		{"itself", []ProcessConfig{{Name: "db", DependsOn: Dependencies{{Process: "db"}}}}, nil, "", "db: cannot depend on itself"},
+		{"healthy without ready check", []ProcessConfig{cache}, Dependencies{{Process: "cache", Condition: ConditionHealthy}}, "",
+			`app: depends_on "cache" with condition "healthy" but "cache" has no ready check`},
+		{"invalid condition", []ProcessConfig{db}, Dependencies{{Process: "db", Condition: "ready"}}, "", `invalid depends_on condition "ready"`},
+		{"cycle", []ProcessConfig{
+			{Name: "a", DependsOn: Dependencies{{Process: "b"}}},
+			{Name: "b", DependsOn: Dependencies{{Process: "c"}}},
+			{Name: "c", DependsOn: Dependencies{{Process: "a"}}},
+		}, nil, "", "depends_on cycle: a -> b -> c -> a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := validateDependencies(tt.processes, tt.app)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, process := range ordered {
+				names = append(names, process.Name)
+			}
+			if got := fmt.Sprint(names); got != tt.wantOrder {
+				t.Fatalf("order = %s, want %s", got, tt.wantOrder)
+			}
+		})
+	}
+
+	// Conditions default to started
+	app := Dependencies{{Process: "db"}}
+	if _, err := validateDependencies([]ProcessConfig{db}, app); err != nil || app[0].Condition != ConditionStarted {
+		t.Fatalf("condition = %q, %v", app[0].Condition, err)
+	}
+}
+
+func TestManagedProcessWaitFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		ready     ReadyCheck
+		condition string
+		wantErr   bool
+	}{
+		{"started", "sleep 30", ReadyCheck{}, ConditionStarted, false},
+		{"healthy by log", "echo listening; sleep 30", ReadyCheck{Log: "listening"}, ConditionHealthy, false},
+		{"healthy but exits", "exit 1", ReadyCheck{Log: "listening"}, ConditionHealthy, true},
+		{"completed successfully", "exit 0", ReadyCheck{}, ConditionCompletedSuccessfully, false},
+		{"completed with error", "exit 3", ReadyCheck{}, ConditionCompletedSuccessfully, true},
+		{"never completes", "sleep 30", ReadyCheck{}, ConditionCompletedSuccessfully, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp, _ := newTestProcess(t, ProcessConfig{
+				Name:    "worker",
+				Command: tt.command,
+				Ready:   tt.ready,
+			})
+			mp.Start("test")
+
+			err := mp.WaitFor(tt.condition, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WaitFor(%s) error = %v, want error %v", tt.condition, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package godevwatch
+
+import (
+	"sync"
+)
+
+const (
+	// eventBufferSize is how many events may be queued for a subscriber before new ones are dropped
+	eventBufferSize = 256
+
+	// eventOutputLimit is how many events may be queued for a subscriber before
+	// output events are dropped, keeping the rest of the buffer for lifecycle events
+	eventOutputLimit = eventBufferSize / 2
+)
+
+// Event is a notification published by the watcher or process manager
+type Event struct {
+	Type MessageType
+	Data interface{}
+}
+
+// EventBus fans events out to in-process subscribers such as the proxy server
+type EventBus struct {
+	subscribers map[chan Event]bool
+	mu          sync.Mutex
+}
+
+// NewEventBus creates a new event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]bool),
+	}
+}
+
+// Publish sends an event to every subscriber without blocking
+func (eb *EventBus) Publish(msgType MessageType, data interface{}) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	for ch := range eb.subscribers {
+		if msgType.isOutput() && len(ch) >= eventOutputLimit {
+			// Output must not crowd out lifecycle events
+			continue
+		}
+		select {
+		case ch <- Event{Type: msgType, Data: data}:
+		default:
+			// Subscriber is not keeping up, drop the event
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a function that unsubscribes it
+func (eb *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	eb.mu.Lock()
+	eb.subscribers[ch] = true
+	eb.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			eb.mu.Lock()
+			delete(eb.subscribers, ch)
+			eb.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package godevwatch
+
+import "testing"
+
+func TestEventBusKeepsRoomForLifecycleEvents(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	// A subscriber that is not reading stops receiving output halfway
+	for i := 0; i < eventBufferSize; i++ {
+		bus.Publish(MessageAppOutput, AppOutputData{Line: "line"})
+	}
+	if len(events) != eventOutputLimit {
+		t.Fatalf("queued %d output events, want %d", len(events), eventOutputLimit)
+	}
+
+	bus.Publish(MessageAppExited, AppExitedData{Process: "app"})
+	for i := 0; i < eventOutputLimit; i++ {
+		<-events
+	}
+	if event := <-events; event.Type != MessageAppExited {
+		t.Fatalf("event = %s, want %s", event.Type, MessageAppExited)
+	}
+}
@@ -0,0 +1,349 @@
+package godevwatch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// WatcherAuto uses fsnotify and falls back to polling when the system's watch limits are hit
+	WatcherAuto = "auto"
+
+	// WatcherFSNotify uses the operating system's file change notifications
+	WatcherFSNotify = "fsnotify"
+
+	// WatcherPoll scans the watched directories for changes at an interval
+	WatcherPoll = "poll"
+
+	// defaultPollInterval is how often watched directories are scanned when polling
+	defaultPollInterval = 500 * time.Millisecond
+)
+
+// EventSource delivers file system events for the directories added to it
+type EventSource interface {
+	// Add watches the files directly inside a directory
+	Add(dir string) error
+
+	// Events returns the channel file events are delivered on
+	Events() <-chan fsnotify.Event
+
+	// Errors returns the channel watch errors are delivered on
+	Errors() <-chan error
+
+	// Close stops watching and closes the channels
+	Close() error
+}
+
+// Validate checks the watcher backend and fills in defaults
+func (wc *WatcherConfig) Validate() error {
+	switch wc.Backend {
+	case "":
+		wc.Backend = WatcherAuto
+	case WatcherAuto, WatcherFSNotify, WatcherPoll:
+	default:
+		return fmt.Errorf("invalid watcher backend %q, expected %q, %q or %q", wc.Backend, WatcherAuto, WatcherFSNotify, WatcherPoll)
+	}
+	if wc.PollInterval <= 0 {
+		wc.PollInterval = defaultPollInterval
+	}
+	return nil
+}
+
+// newEventSource creates the event source selected by the watcher config. In
+// auto mode polling is used if fsnotify cannot be started.
+func newEventSource(config WatcherConfig) (EventSource, error) {
+	if config.Backend == WatcherPoll {
+		return newPollingSource(config.PollInterval), nil
+	}
+
+	source, err := newFSNotifySource()
+	if err != nil {
+		if config.Backend == WatcherFSNotify || !isWatchLimit(err) {
+			return nil, err
+		}
+		log.Printf("\033[33mFile watch limit reached (%v), polling every %s instead\033[0m\n", err, config.PollInterval)
+		return newPollingSource(config.PollInterval), nil
+	}
+	return source, nil
+}
+
+// isWatchLimit reports whether an error means the system ran out of file watches
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// fsnotifySource is an event source backed by fsnotify
+type fsnotifySource struct {
+	watcher *fsnotify.Watcher
+}
+
+// newFSNotifySource creates an fsnotify event source
+func newFSNotifySource() (*fsnotifySource, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	return &fsnotifySource{watcher: watcher}, nil
+}
+
+// Add watches a directory
+func (s *fsnotifySource) Add(dir string) error {
+	return s.watcher.Add(dir)
+}
+
+// Events returns the fsnotify event channel
+func (s *fsnotifySource) Events() <-chan fsnotify.Event {
+	return s.watcher.Events
+}
+
+// Errors returns the fsnotify error channel
+func (s *fsnotifySource) Errors() <-chan error {
+	return s.watcher.Errors
+}
+
+// Close stops the fsnotify watcher
+func (s *fsnotifySource) Close() error {
+	return s.watcher.Close()
+}
+
+// polledFile is the state of a file when its directory was last scanned
+type polledFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// polledDir is the state of a directory when it was last scanned
+type polledDir struct {
+	files   map[string]polledFile
+	subdirs map[string]bool
+}
+
+// pollingSource is an event source that scans its directories at an interval
+// and compares file sizes and modification times, for file systems where change
+// notifications do not arrive, such as Docker bind mounts and network shares
+type pollingSource struct {
+	interval time.Duration
+	dirs     map[string]polledDir
+	events   chan fsnotify.Event
+	errors   chan error
+	done     chan struct{}
+	closed   sync.Once
+	mu       sync.Mutex
+}
+
+// newPollingSource creates a polling event source and starts scanning
+func newPollingSource(interval time.Duration) *pollingSource {
+	s := &pollingSource{
+		interval: interval,
+		dirs:     make(map[string]polledDir),
+		events:   make(chan fsnotify.Event, 100),
+		errors:   make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	go s.poll()
+	return s
+}
+
+// Add records the files in a directory so later scans report their changes
+func (s *pollingSource) Add(dir string) error {
+	state, err := scanDir(dir)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.dirs[dir] = state
+	s.mu.Unlock()
+	return nil
+}
+
+// addCreated watches a directory created since the last scan and the
+// directories below it, returning Create events for them and their files
+func (s *pollingSource) addCreated(dir string) []fsnotify.Event {
+	s.mu.Lock()
+	_, watched := s.dirs[dir]
+	s.mu.Unlock()
+	if watched {
+		return nil
+	}
+
+	state, err := scanDir(dir)
+	if err != nil {
+		return nil // Removed again before it could be scanned
+	}
+	s.mu.Lock()
+	s.dirs[dir] = state
+	s.mu.Unlock()
+
+	events := []fsnotify.Event{{Name: dir, Op: fsnotify.Create}}
+	for name := range state.files {
+		events = append(events, fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Create})
+	}
+	for name := range state.subdirs {
+		events = append(events, s.addCreated(filepath.Join(dir, name))...)
+	}
+	return events
+}
+
+// Events returns the channel file events are delivered on
+func (s *pollingSource) Events() <-chan fsnotify.Event {
+	return s.events
+}
+
+// Errors returns the channel scan errors are delivered on
+func (s *pollingSource) Errors() <-chan error {
+	return s.errors
+}
+
+// Close stops scanning and closes the channels
+func (s *pollingSource) Close() error {
+	s.closed.Do(func() { close(s.done) })
+	return nil
+}
+
+// poll scans the watched directories until the source is closed
+func (s *pollingSource) poll() {
+	defer close(s.errors)
+	defer close(s.events)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !s.scan() {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// scan compares every watched directory with its last scan and delivers the
+// differences, returning false once the source is closed
+func (s *pollingSource) scan() bool {
+	s.mu.Lock()
+	dirs := make([]string, 0, len(s.dirs))
+	for dir := range s.dirs {
+		dirs = append(dirs, dir)
+	}
+	s.mu.Unlock()
+
+	for _, dir := range dirs {
+		s.mu.Lock()
+		previous := s.dirs[dir]
+		s.mu.Unlock()
+
+		current, err := scanDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				if !s.send(nil, err) {
+					return false
+				}
+				continue
+			}
+			// A removed directory removes its files and is no longer watched
+			s.mu.Lock()
+			delete(s.dirs, dir)
+			s.mu.Unlock()
+		}
+
+		var events []fsnotify.Event
+		for name, file := range current.files {
+			path := filepath.Join(dir, name)
+			last, ok := previous.files[name]
+			switch {
+			case !ok:
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+			case file.size != last.size || !file.modTime.Equal(last.modTime):
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+			}
+		}
+		for name := range previous.files {
+			if _, ok := current.files[name]; !ok {
+				events = append(events, fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Remove})
+			}
+		}
+
+		if err == nil {
+			s.mu.Lock()
+			_, watched := s.dirs[dir]
+			if watched {
+				s.dirs[dir] = current
+			}
+			s.mu.Unlock()
+
+			// New subdirectories are watched from now on, like the initial walk does
+			if watched {
+				for name := range current.subdirs {
+					if !previous.subdirs[name] {
+						events = append(events, s.addCreated(filepath.Join(dir, name))...)
+					}
+				}
+			}
+		}
+
+		for i := range events {
+			if !s.send(&events[i], nil) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// send delivers an event or an error, returning false if the source was closed first
+func (s *pollingSource) send(event *fsnotify.Event, err error) bool {
+	if event != nil {
+		select {
+		case s.events <- *event:
+			return true
+		case <-s.done:
+			return false
+		}
+	}
+
+	select {
+	case s.errors <- err:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// scanDir returns the size and modification time of the files directly inside
+// dir, and the subdirectories that are not skipped
+func scanDir(dir string) (polledDir, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return polledDir{}, err
+	}
+
+	state := polledDir{
+		files:   make(map[string]polledFile, len(entries)),
+		subdirs: make(map[string]bool),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if !skipDir(entry.Name()) {
+				state.subdirs[entry.Name()] = true
+			}
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		state.files[entry.Name()] = polledFile{size: info.Size(), modTime: info.ModTime()}
+	}
+	return state, nil
+}
@@ -0,0 +1,156 @@
+package godevwatch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// scanEvents runs one scan of a polling source and returns the events it
+// delivered, with paths relative to dir
+func scanEvents(t *testing.T, s *pollingSource, dir string) []string {
+	t.Helper()
+
+	if !s.scan() {
+		t.Fatal("source closed")
+	}
+	var events []string
+	for {
+		select {
+		case event := <-s.Events():
+			rel, err := filepath.Rel(dir, event.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event.Op.String()+" "+filepath.ToSlash(rel))
+		default:
+			sort.Strings(events)
+			return events
+		}
+	}
+}
+
+func TestPollingSourceScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, dir string)
+		want   []string
+	}{
+		{"nothing changed", func(t *testing.T, dir string) {}, nil},
+		{"file written", func(t *testing.T, dir string) {
+			writeFile(t, dir, "main.go", "package main // changed")
+		}, []string{"WRITE main.go"}},
+		{"file created", func(t *testing.T, dir string) {
+			writeFile(t, dir, "new.go", "package main")
+		}, []string{"CREATE new.go"}},
+		{"file removed", func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, "main.go"))
+		}, []string{"REMOVE main.go"}},
+		{"file in subdirectory written", func(t *testing.T, dir string) {
+			writeFile(t, dir, "pkg/lib.go", "package pkg // changed")
+		}, []string{"WRITE pkg/lib.go"}},
+		{"subdirectory created", func(t *testing.T, dir string) {
+			writeFile(t, dir, "api/handler.go", "package api")
+			writeFile(t, dir, "api/v2/handler.go", "package v2")
+		}, []string{"CREATE api", "CREATE api/handler.go", "CREATE api/v2", "CREATE api/v2/handler.go"}},
+		{"skipped subdirectory created", func(t *testing.T, dir string) {
+			writeFile(t, dir, "node_modules/dep/index.js", "")
+		}, nil},
+		{"subdirectory removed", func(t *testing.T, dir string) {
+			os.RemoveAll(filepath.Join(dir, "pkg"))
+		}, []string{"REMOVE pkg/lib.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "main.go", "package main")
+			writeFile(t, dir, "pkg/lib.go", "package pkg")
+
+			s := newPollingSource(time.Hour)
+			t.Cleanup(func() { s.Close() })
+			for _, watched := range []string{dir, filepath.Join(dir, "pkg")} {
+				if err := s.Add(watched); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Rewrites change the size, as modification times may be too coarse
+			if events := scanEvents(t, s, dir); events != nil {
+				t.Fatalf("initial scan: %v", events)
+			}
+
+			tt.change(t, dir)
+			if events := scanEvents(t, s, dir); fmt.Sprint(events) != fmt.Sprint(tt.want) {
+				t.Fatalf("events = %v, want %v", events, tt.want)
+			}
+
+			// Changes are only reported once
+			if events := scanEvents(t, s, dir); events != nil {
+				t.Fatalf("second scan: %v", events)
+			}
+		})
+	}
+}
+
+func TestPollingSourceWatchesCreatedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	s := newPollingSource(time.Hour)
+	t.Cleanup(func() { s.Close() })
+	if err := s.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "api/v2/handler.go", "package v2")
+	scanEvents(t, s, dir)
+
+	writeFile(t, dir, "api/v2/routes.go", "package v2")
+	if events := scanEvents(t, s, dir); fmt.Sprint(events) != "[CREATE api/v2/routes.go]" {
+		t.Fatalf("events = %v", events)
+	}
+
+	// A directory removed and created again is watched again
+	os.RemoveAll(filepath.Join(dir, "api"))
+	scanEvents(t, s, dir)
+	writeFile(t, dir, "api/handler.go", "package api")
+	if events := scanEvents(t, s, dir); fmt.Sprint(events) != "[CREATE api CREATE api/handler.go]" {
+		t.Fatalf("events after recreating = %v", events)
+	}
+}
+
+// BenchmarkPollingScan scans 20,000 unchanged files in 200 directories
+func BenchmarkPollingScan(b *testing.B) {
+	dir := b.TempDir()
+	s := newPollingSource(time.Hour)
+	defer s.Close()
+
+	for i := 0; i < 200; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("pkg%d", i))
+		if err := os.Mkdir(sub, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 100; j++ {
+			if err := os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%d.go", j)), []byte("package pkg"), 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := s.Add(sub); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.scan()
+	}
+	b.StopTimer()
+
+	select {
+	case event := <-s.Events():
+		b.Fatalf("unexpected event %v", event)
+	default:
+	}
+}
@@ -0,0 +1,267 @@
+package godevwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FaultInjector applies latency and failure rules to proxied requests
+type FaultInjector struct {
+	rules []FaultRule
+	mu    sync.RWMutex
+}
+
+// NewFaultInjector creates a fault injector with the configured rules
+func NewFaultInjector(rules []FaultRule) (*FaultInjector, error) {
+	fi := &FaultInjector{}
+	for _, rule := range rules {
+		if err := fi.Set(rule); err != nil {
+			return nil, err
+		}
+	}
+	return fi, nil
+}
+
+// Rules returns a copy of all rules
+func (fi *FaultInjector) Rules() []FaultRule {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+	return append([]FaultRule{}, fi.rules...)
+}
+
+// Set adds a rule or replaces the rule with the same name
+func (fi *FaultInjector) Set(rule FaultRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("fault rule name is required")
+	}
+	if rule.ErrorRate < 0 || rule.ErrorRate > 1 || rule.ResetRate < 0 || rule.ResetRate > 1 {
+		return fmt.Errorf("fault rates must be between 0 and 1")
+	}
+	if rule.ErrorRate > 0 && rule.ErrorStatus == 0 {
+		rule.ErrorStatus = http.StatusInternalServerError
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	for i, existing := range fi.rules {
+		if existing.Name == rule.Name {
+			fi.rules[i] = rule
+			return nil
+		}
+	}
+	fi.rules = append(fi.rules, rule)
+	return nil
+}
+
+// SetEnabled toggles a rule by name
+func (fi *FaultInjector) SetEnabled(name string, enabled bool) bool {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	for i := range fi.rules {
+		if fi.rules[i].Name == name {
+			fi.rules[i].Enabled = enabled
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes a rule by name
+func (fi *FaultInjector) Remove(name string) bool {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	for i := range fi.rules {
+		if fi.rules[i].Name == name {
+			fi.rules = append(fi.rules[:i], fi.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the first enabled rule that applies to the request
+func (fi *FaultInjector) Match(r *http.Request, backendName string) (FaultRule, bool) {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+
+	for _, rule := range fi.rules {
+		if rule.matches(r, backendName) {
+			return rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+// Apply injects the rule's faults. It returns a writer to use for the rest of
+// the response, or false when the request has been fully handled.
+func (fi *FaultInjector) Apply(rule FaultRule, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, bool) {
+	if delay := rule.delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return w, false
+		}
+	}
+
+	if rule.ResetRate > 0 && rand.Float64() < rule.ResetRate {
+		log.Printf("\033[35m[fault:%s] Resetting connection for %s %s\033[0m\n", rule.Name, r.Method, r.URL.Path)
+		resetConnection(w)
+		return w, false
+	}
+
+	if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+		log.Printf("\033[35m[fault:%s] Returning %d for %s %s\033[0m\n", rule.Name, rule.ErrorStatus, r.Method, r.URL.Path)
+		w.Header().Set("X-Godevwatch-Fault", rule.Name)
+		http.Error(w, fmt.Sprintf("godevwatch: injected fault %q", rule.Name), rule.ErrorStatus)
+		return w, false
+	}
+
+	if rule.BandwidthKBps > 0 {
+		return &throttledWriter{ResponseWriter: w, bytesPerSecond: rule.BandwidthKBps * 1024}, true
+	}
+
+	return w, true
+}
+
+// matches checks if a rule is enabled and applies to the request
+func (rule FaultRule) matches(r *http.Request, backendName string) bool {
+	if !rule.Enabled {
+		return false
+	}
+	if rule.Route != "" && rule.Route != backendName {
+		return false
+	}
+	if rule.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+		return false
+	}
+	if len(rule.Methods) > 0 {
+		found := false
+		for _, method := range rule.Methods {
+			if strings.EqualFold(method, r.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// delay returns the fixed latency plus a random amount up to the jitter
+func (rule FaultRule) delay() time.Duration {
+	delay := rule.Latency
+	if rule.LatencyJitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(rule.LatencyJitter)))
+	}
+	return delay
+}
+
+// resetConnection aborts the client connection without sending a response
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// HTTP/2 connections cannot be hijacked, abort the stream instead
+		panic(http.ErrAbortHandler)
+	}
+
+	// Discard unsent data so the client sees a reset rather than a clean close
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// throttledWriter limits how fast the response body is sent to the client
+type throttledWriter struct {
+	http.ResponseWriter
+	bytesPerSecond int
+}
+
+// Write sends p in chunks, sleeping between them to stay under the bandwidth limit
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	// Send ten chunks per second for a smooth transfer
+	chunk := tw.bytesPerSecond / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	written := 0
+	for written < len(p) {
+		end := min(written+chunk, len(p))
+		n, err := tw.ResponseWriter.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		http.NewResponseController(tw.ResponseWriter).Flush()
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(tw.bytesPerSecond))
+	}
+
+	return written, nil
+}
+
+// Flush flushes buffered data to the client
+func (tw *throttledWriter) Flush() {
+	http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer so http.ResponseController can reach it
+func (tw *throttledWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// MarshalJSON encodes durations as strings
+func (rule FaultRule) MarshalJSON() ([]byte, error) {
+	type plain FaultRule
+	out := struct {
+		plain
+		Latency       string `json:"latency,omitempty"`
+		LatencyJitter string `json:"latency_jitter,omitempty"`
+	}{plain: plain(rule)}
+	if rule.Latency > 0 {
+		out.Latency = rule.Latency.String()
+	}
+	if rule.LatencyJitter > 0 {
+		out.LatencyJitter = rule.LatencyJitter.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes durations from strings such as "250ms"
+func (rule *FaultRule) UnmarshalJSON(data []byte) error {
+	type plain FaultRule
+	var in struct {
+		*plain
+		Latency       string `json:"latency,omitempty"`
+		LatencyJitter string `json:"latency_jitter,omitempty"`
+	}
+	in.plain = (*plain)(rule)
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	var err error
+	if in.Latency != "" {
+		if rule.Latency, err = time.ParseDuration(in.Latency); err != nil {
+			return fmt.Errorf("invalid latency: %w", err)
+		}
+	}
+	if in.LatencyJitter != "" {
+		if rule.LatencyJitter, err = time.ParseDuration(in.LatencyJitter); err != nil {
+			return fmt.Errorf("invalid latency_jitter: %w", err)
+		}
+	}
+	return nil
+}
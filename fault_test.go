@@ -0,0 +1,190 @@
+package godevwatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultInjectorSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       FaultRule
+		wantErr    string
+		wantStatus int
+	}{
+		{"latency only", FaultRule{Name: "slow", Latency: time.Second}, "", 0},
+		{"error status defaults to 500", FaultRule{Name: "flaky", ErrorRate: 0.5}, "", http.StatusInternalServerError},
+		{"explicit error status", FaultRule{Name: "flaky", ErrorRate: 1, ErrorStatus: 503}, "", 503},
+		{"missing name", FaultRule{Latency: time.Second}, "name is required", 0},
+		{"error rate above 1", FaultRule{Name: "bad", ErrorRate: 1.5}, "between 0 and 1", 0},
+		{"negative reset rate", FaultRule{Name: "bad", ResetRate: -0.1}, "between 0 and 1", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fi := &FaultInjector{}
+			err := fi.Set(tt.rule)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rules := fi.Rules(); len(rules) != 1 || rules[0].ErrorStatus != tt.wantStatus {
+				t.Fatalf("rules = %+v", rules)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorMatch(t *testing.T) {
+	fi, err := NewFaultInjector([]FaultRule{
+		{Name: "disabled", PathPrefix: "/"},
+		{Name: "api-writes", Enabled: true, Route: "api", PathPrefix: "/api/", Methods: []string{"post", "PUT"}},
+		{Name: "everything", Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method  string
+		path    string
+		backend string
+		want    string
+	}{
+		{http.MethodPost, "/api/items", "api", "api-writes"},
+		{http.MethodPut, "/api/items/1", "api", "api-writes"},
+		{http.MethodGet, "/api/items", "api", "everything"},
+		{http.MethodPost, "/api/items", "default", "everything"},
+		{http.MethodPost, "/other", "api", "everything"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.backend+tt.path, func(t *testing.T) {
+			rule, ok := fi.Match(httptest.NewRequest(tt.method, tt.path, nil), tt.backend)
+			if !ok || rule.Name != tt.want {
+				t.Fatalf("matched %q (%v), want %q", rule.Name, ok, tt.want)
+			}
+		})
+	}
+
+	// Rules can be toggled, replaced and removed at runtime
+	if !fi.SetEnabled("everything", false) || fi.SetEnabled("missing", true) {
+		t.Fatal("SetEnabled")
+	}
+	if _, ok := fi.Match(httptest.NewRequest(http.MethodGet, "/", nil), "default"); ok {
+		t.Fatal("disabled rule matched")
+	}
+	fi.Set(FaultRule{Name: "api-writes", Enabled: true})
+	if len(fi.Rules()) != 3 {
+		t.Fatalf("Set did not replace the rule: %+v", fi.Rules())
+	}
+	if !fi.Remove("api-writes") || fi.Remove("api-writes") {
+		t.Fatal("Remove")
+	}
+}
+
+func TestFaultInjectorApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     FaultRule
+		handled  bool
+		status   int
+		minDelay time.Duration
+	}{
+		{"no faults", FaultRule{Name: "noop"}, false, http.StatusOK, 0},
+		{"latency", FaultRule{Name: "slow", Latency: 50 * time.Millisecond}, false, http.StatusOK, 50 * time.Millisecond},
+		{"error", FaultRule{Name: "down", ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}, true, http.StatusServiceUnavailable, 0},
+		{"bandwidth", FaultRule{Name: "3g", BandwidthKBps: 1}, false, http.StatusOK, 150 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fi := &FaultInjector{}
+			w := httptest.NewRecorder()
+			start := time.Now()
+
+			writer, proceed := fi.Apply(tt.rule, w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if proceed == tt.handled {
+				t.Fatalf("proceed = %v", proceed)
+			}
+			if proceed {
+				writer.Write([]byte(strings.Repeat("x", 200)))
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.handled && w.Header().Get("X-Godevwatch-Fault") != tt.rule.Name {
+				t.Errorf("fault header = %q", w.Header().Get("X-Godevwatch-Fault"))
+			}
+			if elapsed := time.Since(start); elapsed < tt.minDelay {
+				t.Errorf("took %s, want at least %s", elapsed, tt.minDelay)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorReset(t *testing.T) {
+	fi := &FaultInjector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fi.Apply(FaultRule{Name: "reset", ResetRate: 1}, w, r)
+	}))
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("got a %d response, want a reset connection", resp.StatusCode)
+	}
+}
+
+func TestFaultRuleJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    FaultRule
+		wantErr string
+	}{
+		{"durations", `{"name":"slow","enabled":true,"latency":"250ms","latency_jitter":"1s"}`,
+			FaultRule{Name: "slow", Enabled: true, Latency: 250 * time.Millisecond, LatencyJitter: time.Second}, ""},
+		{"no durations", `{"name":"down","error_rate":0.5}`, FaultRule{Name: "down", ErrorRate: 0.5}, ""},
+		{"invalid latency", `{"name":"slow","latency":"soon"}`, FaultRule{}, "invalid latency"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule FaultRule
+			err := json.Unmarshal([]byte(tt.json), &rule)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rule.Name != tt.want.Name || rule.Latency != tt.want.Latency || rule.LatencyJitter != tt.want.LatencyJitter || rule.ErrorRate != tt.want.ErrorRate {
+				t.Fatalf("rule = %+v, want %+v", rule, tt.want)
+			}
+
+			// Encoding writes the durations back as strings
+			data, err := json.Marshal(rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded FaultRule
+			if err := json.Unmarshal(data, &decoded); err != nil || decoded.Latency != rule.Latency {
+				t.Fatalf("round trip %s: %+v %v", data, decoded, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package godevwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// LogFormatRaw passes application output through unchanged
+	LogFormatRaw = "raw"
+
+	// LogFormatPretty renders JSON and logfmt lines as aligned, colored text
+	LogFormatPretty = "pretty"
+
+	// messageWidth is the column structured fields are aligned to after the message
+	messageWidth = 40
+)
+
+// logLevels ranks normalized level names for filtering
+var logLevels = map[string]int{
+	"trace": 0,
+	"debug": 1,
+	"info":  2,
+	"warn":  3,
+	"error": 4,
+	"fatal": 5,
+}
+
+// levelColors are the ANSI colors used for each level
+var levelColors = map[string]string{
+	"trace": "\033[90m",
+	"debug": "\033[90m",
+	"info":  "\033[32m",
+	"warn":  "\033[33m",
+	"error": "\033[31m",
+	"fatal": "\033[1;31m",
+}
+
+// Keys recognized in slog, zap, zerolog and logrus output
+var (
+	timeKeys    = []string{"time", "ts", "timestamp", "@timestamp"}
+	levelKeys   = []string{"level", "lvl", "severity"}
+	messageKeys = []string{"msg", "message"}
+)
+
+// LogFormatter renders structured application output for the terminal
+type LogFormatter struct {
+	pretty   bool
+	minLevel int
+}
+
+// logField is a key and its rendered value, in the order it appeared
+type logField struct {
+	key   string
+	value string
+}
+
+// structuredLine is a parsed JSON or logfmt log line
+type structuredLine struct {
+	time    string
+	level   string
+	message string
+	fields  []logField
+}
+
+// NewLogFormatter creates a formatter from the logs configuration
+func NewLogFormatter(config LogsConfig) (*LogFormatter, error) {
+	lf := &LogFormatter{}
+
+	switch config.Format {
+	case "", LogFormatRaw:
+	case LogFormatPretty:
+		lf.pretty = true
+	default:
+		return nil, fmt.Errorf("invalid logs.format %q, expected %q or %q", config.Format, LogFormatRaw, LogFormatPretty)
+	}
+
+	if config.Level != "" {
+		rank, ok := logLevels[normalizeLevel(config.Level)]
+		if !ok {
+			return nil, fmt.Errorf("invalid logs.level %q", config.Level)
+		}
+		lf.minLevel = rank
+	}
+
+	return lf, nil
+}
+
+// Format returns the line to print, its normalized level if it is structured,
+// and whether it passes the level filter
+func (lf *LogFormatter) Format(line string) (string, string, bool) {
+	if !lf.pretty {
+		return line, "", true
+	}
+
+	parsed, ok := parseStructured(line)
+	if !ok {
+		return line, "", true
+	}
+
+	if rank, known := logLevels[parsed.level]; known && rank < lf.minLevel {
+		return "", parsed.level, false
+	}
+
+	return parsed.render(), parsed.level, true
+}
+
+// parseStructured detects and parses JSON and logfmt log lines
+func parseStructured(line string) (structuredLine, bool) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "{") {
+		fields, ok := parseJSONFields(trimmed)
+		if !ok {
+			return structuredLine{}, false
+		}
+		return newStructuredLine(fields)
+	}
+
+	fields, ok := parseLogfmt(trimmed)
+	if !ok {
+		return structuredLine{}, false
+	}
+	return newStructuredLine(fields)
+}
+
+// newStructuredLine picks out the time, level and message from the fields.
+// Lines without a level or message are not treated as structured logs.
+func newStructuredLine(fields []logField) (structuredLine, bool) {
+	var sl structuredLine
+	var foundLevel, foundMessage bool
+
+	for _, field := range fields {
+		switch {
+		case sl.time == "" && contains(timeKeys, field.key):
+			sl.time = formatLogTime(field.value)
+		case !foundLevel && contains(levelKeys, field.key):
+			sl.level = normalizeLevel(field.value)
+			foundLevel = true
+		case !foundMessage && contains(messageKeys, field.key):
+			sl.message = field.value
+			foundMessage = true
+		default:
+			sl.fields = append(sl.fields, field)
+		}
+	}
+
+	return sl, foundLevel || foundMessage
+}
+
+// render formats a structured line as "time LEVEL message key=value ..."
+func (sl structuredLine) render() string {
+	var b strings.Builder
+
+	if sl.time != "" {
+		b.WriteString("\033[90m" + sl.time + "\033[0m ")
+	}
+
+	label := strings.ToUpper(sl.level)
+	if label == "" {
+		label = "-"
+	}
+	color := levelColors[sl.level]
+	fmt.Fprintf(&b, "%s%-5s\033[0m ", color, label)
+
+	b.WriteString(sl.message)
+	if len(sl.fields) > 0 {
+		if pad := messageWidth - len(sl.message); pad > 0 {
+			b.WriteString(strings.Repeat(" ", pad))
+		}
+		for _, field := range sl.fields {
+			fmt.Fprintf(&b, " \033[36m%s\033[0m=%s", field.key, quoteIfNeeded(field.value))
+		}
+	}
+
+	return b.String()
+}
+
+// parseJSONFields decodes a JSON object keeping its keys in order
+func parseJSONFields(line string) ([]logField, bool) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+
+	var fields []logField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, false
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, false
+		}
+		fields = append(fields, logField{key: key, value: jsonValue(raw)})
+	}
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('}') {
+		return nil, false
+	}
+
+	return fields, true
+}
+
+// jsonValue renders a JSON value, unquoting strings and compacting objects
+func jsonValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err == nil {
+		return buf.String()
+	}
+	return string(raw)
+}
+
+// parseLogfmt parses key=value pairs, requiring every token to be a pair
+func parseLogfmt(line string) ([]logField, bool) {
+	var fields []logField
+
+	for i := 0; i < len(line); {
+		// Skip whitespace between pairs
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		if i >= len(line) || line[i] != '=' || i == start {
+			return nil, false
+		}
+		key := line[start:i]
+		i++
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, false
+			}
+			value = unquoted
+			i = end + 1
+		} else {
+			start := i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value = line[start:i]
+		}
+
+		fields = append(fields, logField{key: key, value: value})
+	}
+
+	return fields, len(fields) >= 2
+}
+
+// normalizeLevel maps level names used by common loggers onto logLevels
+func normalizeLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch level {
+	case "warning":
+		return "warn"
+	case "err":
+		return "error"
+	case "panic", "dpanic", "critical", "crit", "fatal":
+		return "fatal"
+	}
+
+	// slog levels such as "INFO+2" or "DEBUG-4"
+	if i := strings.IndexAny(level, "+-"); i > 0 {
+		return normalizeLevel(level[:i])
+	}
+	return level
+}
+
+// formatLogTime shortens RFC 3339 and Unix timestamps to local wall-clock time
+func formatLogTime(value string) string {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.Local().Format("15:04:05.000")
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		// zap writes seconds, other loggers may write milliseconds
+		if f > 1e12 {
+			f /= 1000
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).Local().Format("15:04:05.000")
+	}
+	return value
+}
+
+// quoteIfNeeded quotes values containing spaces or quotes, leaving nested JSON as is
+func quoteIfNeeded(value string) string {
+	if strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") {
+		return value
+	}
+	if value == "" || strings.IndexFunc(value, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == '=' }) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// contains reports whether s is in list
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
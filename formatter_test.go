@@ -0,0 +1,145 @@
+package godevwatch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLogFormatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LogsConfig
+		wantErr string
+	}{
+		{"defaults", LogsConfig{}, ""},
+		{"pretty with level", LogsConfig{Format: LogFormatPretty, Level: "WARNING"}, ""},
+		{"unknown format", LogsConfig{Format: "color"}, `invalid logs.format "color"`},
+		{"unknown level", LogsConfig{Level: "loud"}, `invalid logs.level "loud"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLogFormatter(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogFormatterFormat(t *testing.T) {
+	pad := strings.Repeat(" ", messageWidth-len("started"))
+
+	tests := []struct {
+		name      string
+		config    LogsConfig
+		line      string
+		want      string
+		wantLevel string
+		wantShow  bool
+	}{
+		{"raw passes through", LogsConfig{}, `{"level":"info","msg":"started"}`, `{"level":"info","msg":"started"}`, "", true},
+		{"plain text", LogsConfig{Format: LogFormatPretty}, "listening on :8080", "listening on :8080", "", true},
+		{"slog json", LogsConfig{Format: LogFormatPretty}, `{"level":"INFO","msg":"started","port":8080}`,
+			"\033[32mINFO \033[0m started" + pad + " \033[36mport\033[0m=8080", "info", true},
+		{"nested json", LogsConfig{Format: LogFormatPretty}, `{"severity":"warning","message":"slow","req":{"id": 1}}`,
+			"\033[33mWARN \033[0m slow" + strings.Repeat(" ", messageWidth-4) + " \033[36mreq\033[0m={\"id\":1}", "warn", true},
+		{"logfmt", LogsConfig{Format: LogFormatPretty}, `lvl=error msg="db down" err="dial tcp: refused"`,
+			"\033[31mERROR\033[0m db down" + strings.Repeat(" ", messageWidth-7) + " \033[36merr\033[0m=\"dial tcp: refused\"", "error", true},
+		{"message without level", LogsConfig{Format: LogFormatPretty}, `{"msg":"started"}`, "-    \033[0m started", "", true},
+		{"json without level or message", LogsConfig{Format: LogFormatPretty}, `{"a":1}`, `{"a":1}`, "", true},
+		{"invalid json", LogsConfig{Format: LogFormatPretty}, `{"level":"info"`, `{"level":"info"`, "", true},
+		{"single pair is not logfmt", LogsConfig{Format: LogFormatPretty}, "level=info", "level=info", "", true},
+		{"filtered by level", LogsConfig{Format: LogFormatPretty, Level: "warn"}, `{"level":"debug","msg":"tick"}`, "", "debug", false},
+		{"slog level offset", LogsConfig{Format: LogFormatPretty, Level: "warn"}, `{"level":"DEBUG+4","msg":"tick"}`, "", "debug", false},
+		{"unknown level kept", LogsConfig{Format: LogFormatPretty, Level: "warn"}, `{"level":"notice","msg":"tick"}`,
+			"NOTICE\033[0m tick", "notice", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lf, err := NewLogFormatter(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, level, show := lf.Format(tt.line)
+			if got != tt.want || level != tt.wantLevel || show != tt.wantShow {
+				t.Fatalf("Format = %q %q %v, want %q %q %v", got, level, show, tt.want, tt.wantLevel, tt.wantShow)
+			}
+		})
+	}
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  string
+	}{
+		{"INFO", "info"},
+		{" Warning ", "warn"},
+		{"err", "error"},
+		{"dpanic", "fatal"},
+		{"CRITICAL", "fatal"},
+		{"INFO+2", "info"},
+		{"DEBUG-4", "debug"},
+		{"notice", "notice"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeLevel(tt.level); got != tt.want {
+			t.Errorf("normalizeLevel(%q) = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLogTime(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 45, 500000000, time.UTC)
+	want := at.Local().Format("15:04:05.000")
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"rfc3339", "2024-03-01T12:30:45.5Z", want},
+		{"unix seconds", "1709296245.5", want},
+		{"unix milliseconds", "1709296245500", want},
+		{"unknown format kept", "yesterday", "yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLogTime(tt.value); got != tt.want {
+				t.Fatalf("formatLogTime(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteIfNeeded(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"a=b", `"a=b"`},
+		{`{"id":1}`, `{"id":1}`},
+		{"[1, 2]", "[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		if got := quoteIfNeeded(tt.value); got != tt.want {
+			t.Errorf("quoteIfNeeded(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
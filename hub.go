@@ -0,0 +1,221 @@
+package godevwatch
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is the time allowed to write a message to a client
+	writeWait = 10 * time.Second
+
+	// pongWait is the time allowed to read the next pong from a client
+	pongWait = 60 * time.Second
+
+	// pingPeriod is how often clients are pinged, must be less than pongWait
+	pingPeriod = pongWait * 9 / 10
+
+	// maxMessageSize is the largest message accepted from a client
+	maxMessageSize = 4096
+
+	// sendBufferSize is how many messages may be queued for a client before it is evicted
+	sendBufferSize = 64
+
+	// sendOutputLimit is how many messages may be queued for a client before
+	// output messages are dropped for it instead of evicting it
+	sendOutputLimit = sendBufferSize / 2
+)
+
+// Hub tracks connected WebSocket clients and fans messages out to them. All
+// client bookkeeping happens on the hub's goroutine; each client has its own
+// writer goroutine so a slow browser cannot block broadcasts.
+type Hub struct {
+	clients    map[*hubClient]bool
+	register   chan *hubClient
+	unregister chan *hubClient
+	broadcast  chan hubMessage
+	done       chan struct{}
+	stopOnce   sync.Once
+
+	// pongWait and pingPeriod are the keepalive timings of new clients
+	pongWait   time.Duration
+	pingPeriod time.Duration
+}
+
+// hubMessage is a broadcast message. Output messages are dropped for clients
+// that are falling behind so they never cause lifecycle messages to be lost.
+type hubMessage struct {
+	data   []byte
+	output bool
+}
+
+// hubClient is a single WebSocket connection with a buffered send queue
+type hubClient struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// NewHub creates a new hub and starts its event loop
+func NewHub() *Hub {
+	h := &Hub{
+		clients:    make(map[*hubClient]bool),
+		register:   make(chan *hubClient),
+		unregister: make(chan *hubClient),
+		broadcast:  make(chan hubMessage),
+		done:       make(chan struct{}),
+		pongWait:   pongWait,
+		pingPeriod: pingPeriod,
+	}
+	go h.run()
+	return h
+}
+
+// run owns the client set and processes registrations and broadcasts
+func (h *Hub) run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.clients[client] = true
+
+		case client := <-h.unregister:
+			h.remove(client)
+
+		case message := <-h.broadcast:
+			for client := range h.clients {
+				if message.output && len(client.send) >= sendOutputLimit {
+					// Client is behind, skip output rather than evicting it
+					continue
+				}
+				select {
+				case client.send <- message.data:
+				default:
+					// Client is not keeping up, drop it rather than blocking everyone else
+					log.Printf("Evicting slow WebSocket client %s", client.conn.RemoteAddr())
+					h.remove(client)
+				}
+			}
+
+		case <-h.done:
+			for client := range h.clients {
+				h.remove(client)
+			}
+			return
+		}
+	}
+}
+
+// remove drops a client and closes its send queue, which stops its writer
+func (h *Hub) remove(client *hubClient) {
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// Serve registers a connection with the hub and starts its reader and writer
+// goroutines. initial messages are queued before any broadcast.
+func (h *Hub) Serve(conn *websocket.Conn, initial ...[]byte) {
+	client := &hubClient{
+		hub:  h,
+		conn: conn,
+		send: make(chan []byte, sendBufferSize),
+	}
+
+	for _, message := range initial {
+		client.send <- message
+	}
+
+	select {
+	case h.register <- client:
+	case <-h.done:
+		conn.Close()
+		return
+	}
+
+	go client.writePump()
+	go client.readPump()
+}
+
+// Broadcast queues a message for every connected client
+func (h *Hub) Broadcast(message []byte) {
+	h.queue(hubMessage{data: message})
+}
+
+// BroadcastOutput queues an output message for every connected client that is
+// keeping up, dropping it for clients that are not
+func (h *Hub) BroadcastOutput(message []byte) {
+	h.queue(hubMessage{data: message, output: true})
+}
+
+// queue hands a message to the hub's goroutine
+func (h *Hub) queue(message hubMessage) {
+	select {
+	case h.broadcast <- message:
+	case <-h.done:
+	}
+}
+
+// Stop disconnects all clients and stops the hub
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// readPump reads from the connection to process pongs and detect disconnects
+func (c *hubClient) readPump() {
+	defer func() {
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump writes queued messages and pings to the connection. It is the
+// only goroutine that writes to the connection.
+func (c *hubClient) writePump() {
+	ticker := time.NewTicker(c.hub.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Hub closed the queue
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package godevwatch
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// hubHello is the first initial message sent to test clients, so reading it
+// means the client is registered and its writer is running
+const hubHello = "hello"
+
+// newTestHub serves a new hub over a test server, sending initial after hubHello
+func newTestHub(t *testing.T, initial ...string) (*Hub, string) {
+	t.Helper()
+
+	hub := NewHub()
+	return hub, serveHub(t, hub, initial...)
+}
+
+// serveHub serves hub over a test server and returns its WebSocket URL
+func serveHub(t *testing.T, hub *Hub, initial ...string) string {
+	t.Helper()
+	t.Cleanup(hub.Stop)
+
+	messages := [][]byte{[]byte(hubHello)}
+	for _, message := range initial {
+		messages = append(messages, []byte(message))
+	}
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, messages...)
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dialHub connects a client to a test hub and waits until it is registered
+func dialHub(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if got := readHub(t, conn); got != hubHello {
+		t.Fatalf("first message = %q", got)
+	}
+	return conn
+}
+
+// readHub reads the next text message, failing if none arrives within a few seconds
+func readHub(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(message)
+}
+
+// waitClosed reads from conn until the hub closes it, failing after timeout,
+// and returns how many messages were still delivered
+func waitClosed(t *testing.T, conn *websocket.Conn, timeout time.Duration) int {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for count := 0; ; count++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatalf("connection still open after %s", timeout)
+			}
+			return count
+		}
+	}
+}
+
+func TestHubInitialMessagesFirst(t *testing.T) {
+	hub, url := newTestHub(t, "build-status", "server-status")
+
+	// Broadcasts race the connection, but never overtake its initial messages
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				hub.Broadcast([]byte("broadcast"))
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var got []string
+	for len(got) < 4 {
+		got = append(got, readHub(t, conn))
+	}
+	want := []string{hubHello, "build-status", "server-status", "broadcast"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("messages = %v, want %v", got, want)
+	}
+}
+
+func TestHubConcurrentBroadcastAndRegister(t *testing.T) {
+	hub, url := newTestHub(t)
+	stayer := dialHub(t, url)
+
+	// Fewer broadcasts than fit in a send queue, so no client can be evicted
+	const broadcasts = 40
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < broadcasts/4; j++ {
+				hub.Broadcast([]byte("tick"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				conn.Close()
+			}
+		}()
+	}
+
+	// The long-lived client keeps up with every broadcast
+	received := make(chan int)
+	go func() {
+		count := 0
+		stayer.SetReadDeadline(time.Now().Add(10 * time.Second))
+		for count < broadcasts {
+			if _, _, err := stayer.ReadMessage(); err != nil {
+				break
+			}
+			count++
+		}
+		received <- count
+	}()
+
+	wg.Wait()
+	if count := <-received; count != broadcasts {
+		t.Fatalf("received %d of %d broadcasts", count, broadcasts)
+	}
+}
+
+func TestHubEvictsSlowClient(t *testing.T) {
+	hub, url := newTestHub(t)
+	slow := dialHub(t, url)
+	fast := dialHub(t, url)
+
+	// Large messages fill the slow client's connection, then its send queue,
+	// while the fast client reads each one before the next is sent
+	const broadcasts = 4 * sendBufferSize
+	message := bytes.Repeat([]byte("x"), 512*1024)
+	for i := 0; i < broadcasts; i++ {
+		hub.Broadcast(message)
+		if got := readHub(t, fast); len(got) != len(message) {
+			t.Fatalf("fast client read %d bytes", len(got))
+		}
+	}
+
+	// The slow client gets what was queued before it was evicted, then is closed
+	if delivered := waitClosed(t, slow, 10*time.Second); delivered >= broadcasts {
+		t.Fatalf("slow client received all %d broadcasts", delivered)
+	}
+}
+
+func TestHubDropsOutputForSlowClient(t *testing.T) {
+	hub, url := newTestHub(t)
+	slow := dialHub(t, url)
+	fast := dialHub(t, url)
+
+	// Output fills the slow client's connection, then half its send queue
+	const broadcasts = 4 * sendBufferSize
+	message := bytes.Repeat([]byte("x"), 512*1024)
+	for i := 0; i < broadcasts; i++ {
+		hub.BroadcastOutput(message)
+		if got := readHub(t, fast); len(got) != len(message) {
+			t.Fatalf("fast client read %d bytes", len(got))
+		}
+	}
+	hub.Broadcast([]byte("reload"))
+
+	// The slow client misses output but is kept, and still gets the lifecycle message
+	delivered := 0
+	for readHub(t, slow) != "reload" {
+		delivered++
+	}
+	if delivered >= broadcasts {
+		t.Fatalf("slow client received all %d broadcasts", delivered)
+	}
+	if got := readHub(t, fast); got != "reload" {
+		t.Fatalf("fast client read %q", got)
+	}
+}
+
+func TestHubPongTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers bool
+		evicted bool
+	}{
+		{"client answering pings stays", true, false},
+		{"silent client is dropped", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			hub.pongWait = 200 * time.Millisecond
+			hub.pingPeriod = 50 * time.Millisecond
+			conn := dialHub(t, serveHub(t, hub))
+
+			// Reading answers pings, a client that stops reading never does
+			messages := make(chan string, 1)
+			if tt.answers {
+				go func() {
+					defer close(messages)
+					for {
+						_, message, err := conn.ReadMessage()
+						if err != nil {
+							return
+						}
+						messages <- string(message)
+					}
+				}()
+			}
+			time.Sleep(3 * hub.pongWait)
+
+			if tt.evicted {
+				waitClosed(t, conn, time.Second)
+				return
+			}
+			hub.Broadcast([]byte("still here"))
+			select {
+			case message, ok := <-messages:
+				if !ok {
+					t.Fatal("client dropped")
+				}
+				if message != "still here" {
+					t.Fatalf("message = %q", message)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no broadcast received")
+			}
+		})
+	}
+}
+
+func TestHubStopClosesClients(t *testing.T) {
+	hub, url := newTestHub(t)
+	clients := []*websocket.Conn{dialHub(t, url), dialHub(t, url), dialHub(t, url)}
+
+	hub.Stop()
+	for i, conn := range clients {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, _, err := conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseNoStatusReceived, websocket.CloseNormalClosure) {
+			t.Fatalf("client %d: err = %v, want a close frame", i, err)
+		}
+	}
+
+	// Connections after stopping are closed right away
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitClosed(t, conn, 5*time.Second)
+}
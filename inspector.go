@@ -0,0 +1,385 @@
+package godevwatch
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// RequestRecord is a single proxied request captured by the inspector
+type RequestRecord struct {
+	ID              int64       `json:"id"`
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	Host            string      `json:"host"`
+	Path            string      `json:"path"`
+	Query           string      `json:"query,omitempty"`
+	Backend         string      `json:"backend,omitempty"`
+	Status          int         `json:"status"`
+	DurationMs      float64     `json:"duration_ms"`
+	RequestSize     int64       `json:"request_size"`
+	ResponseSize    int64       `json:"response_size"`
+	RequestHeaders  http.Header `json:"request_headers,omitempty"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+	Truncated       bool        `json:"truncated,omitempty"`
+
+	// Unredacted copies kept for replaying the request
+	rawQuery          string
+	rawRequestHeaders http.Header
+	rawRequestBody    []byte
+	rawResponseBody   []byte
+	requestTruncated  bool
+}
+
+// redactRule replaces a sensitive value matched by re
+type redactRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// RequestInspector records proxied requests into a fixed-size ring buffer
+type RequestInspector struct {
+	config  InspectorConfig
+	redact  []redactRule
+	records []RequestRecord
+	next    int
+	count   int
+	nextID  int64
+	mu      sync.Mutex
+}
+
+// NewRequestInspector creates a new request inspector
+func NewRequestInspector(config InspectorConfig) *RequestInspector {
+	if config.BufferSize <= 0 {
+		config.BufferSize = 200
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 64 * 1024
+	}
+
+	ri := &RequestInspector{
+		config:  config,
+		records: make([]RequestRecord, config.BufferSize),
+	}
+
+	for _, name := range config.Redact {
+		quoted := regexp.QuoteMeta(name)
+		ri.redact = append(ri.redact,
+			// JSON fields: "name": "value"
+			redactRule{regexp.MustCompile(`(?i)("` + quoted + `"\s*:\s*)"(?:[^"\\]|\\.)*"`), `${1}"` + redactedValue + `"`},
+			// Form and query fields: name=value
+			redactRule{regexp.MustCompile(`(?i)((?:^|&)` + quoted + `=)[^&]*`), `${1}` + redactedValue},
+		)
+	}
+
+	return ri
+}
+
+// add stores a record in the ring buffer, overwriting the oldest when full
+func (ri *RequestInspector) add(record RequestRecord) RequestRecord {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	ri.nextID++
+	record.ID = ri.nextID
+	ri.records[ri.next] = record
+	ri.next = (ri.next + 1) % len(ri.records)
+	if ri.count < len(ri.records) {
+		ri.count++
+	}
+
+	return record
+}
+
+// Records returns all recorded requests, newest first
+func (ri *RequestInspector) Records() []RequestRecord {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	records := make([]RequestRecord, 0, ri.count)
+	for i := 1; i <= ri.count; i++ {
+		idx := (ri.next - i + len(ri.records)) % len(ri.records)
+		records = append(records, ri.records[idx])
+	}
+	return records
+}
+
+// Get returns the record with the given ID if it is still in the buffer
+func (ri *RequestInspector) Get(id int64) (RequestRecord, bool) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	for i := 0; i < ri.count; i++ {
+		if ri.records[i].ID == id {
+			return ri.records[i], true
+		}
+	}
+	return RequestRecord{}, false
+}
+
+// Clear removes all recorded requests
+func (ri *RequestInspector) Clear() {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	ri.records = make([]RequestRecord, len(ri.records))
+	ri.next = 0
+	ri.count = 0
+}
+
+// Capture starts recording a request. The returned writer must be used for
+// the response and Finish called once it has been written.
+func (ri *RequestInspector) Capture(w http.ResponseWriter, r *http.Request) *CaptureWriter {
+	cw := &CaptureWriter{
+		ResponseWriter: w,
+		inspector:      ri,
+		request:        r,
+		start:          time.Now(),
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		// Request bodies are always kept up to the limit so they can be replayed
+		cw.requestBody = &captureReader{
+			ReadCloser: r.Body,
+			limit:      ri.config.MaxBodySize,
+		}
+		r.Body = cw.requestBody
+	}
+
+	return cw
+}
+
+// bodyLimit returns how many body bytes to keep, or 0 when bodies are not captured
+func (ri *RequestInspector) bodyLimit() int {
+	if !ri.config.CaptureBodies {
+		return 0
+	}
+	return ri.config.MaxBodySize
+}
+
+// redactHeaders returns a copy of header with sensitive values replaced
+func (ri *RequestInspector) redactHeaders(header http.Header) http.Header {
+	copied := header.Clone()
+	for _, name := range ri.config.Redact {
+		key := http.CanonicalHeaderKey(name)
+		if _, ok := copied[key]; ok {
+			copied[key] = []string{redactedValue}
+		}
+	}
+	return copied
+}
+
+// redactBody replaces sensitive JSON and form field values in body
+func (ri *RequestInspector) redactBody(body string) string {
+	for _, rule := range ri.redact {
+		body = rule.re.ReplaceAllString(body, rule.replacement)
+	}
+	return body
+}
+
+// logRequest prints a compact colored access log line
+func (ri *RequestInspector) logRequest(record RequestRecord) {
+	color := "32"
+	switch {
+	case record.Status >= 500:
+		color = "31"
+	case record.Status >= 400:
+		color = "33"
+	case record.Status >= 300:
+		color = "36"
+	}
+
+	path := record.Path
+	if record.Query != "" {
+		path += "?" + record.Query
+	}
+
+	log.Printf("\033[%sm%d\033[0m %-6s %s \033[2m%.1fms %s\033[0m\n",
+		color, record.Status, record.Method, path, record.DurationMs, formatSize(record.ResponseSize))
+}
+
+// CaptureWriter records the status, size and body of a response as it is written
+type CaptureWriter struct {
+	http.ResponseWriter
+	inspector   *RequestInspector
+	request     *http.Request
+	requestBody *captureReader
+	start       time.Time
+	backend     string
+	status      int
+	size        int64
+	body        bytes.Buffer
+	truncated   bool
+}
+
+// WriteHeader records the response status
+func (cw *CaptureWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records response bytes up to the body limit
+func (cw *CaptureWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	n, err := cw.ResponseWriter.Write(p)
+	cw.size += int64(n)
+
+	if limit := cw.inspector.bodyLimit(); limit > 0 {
+		remaining := limit - cw.body.Len()
+		if remaining >= n {
+			cw.body.Write(p[:n])
+		} else {
+			if remaining > 0 {
+				cw.body.Write(p[:remaining])
+			}
+			cw.truncated = true
+		}
+	}
+
+	return n, err
+}
+
+// Flush flushes buffered data to the client, used by streaming responses
+func (cw *CaptureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection for protocol upgrades such as WebSockets
+func (cw *CaptureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil && cw.status == 0 {
+		cw.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying writer so http.ResponseController can reach it
+func (cw *CaptureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// SetBackend records which backend handled the request
+func (cw *CaptureWriter) SetBackend(name string) {
+	cw.backend = name
+}
+
+// Finish stores the completed request in the inspector and logs it
+func (cw *CaptureWriter) Finish() RequestRecord {
+	ri := cw.inspector
+	r := cw.request
+
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	record := RequestRecord{
+		Time:         cw.start,
+		Method:       r.Method,
+		Host:         r.Host,
+		Path:         r.URL.Path,
+		Query:        ri.redactBody(r.URL.RawQuery),
+		Backend:      cw.backend,
+		Status:       status,
+		DurationMs:   float64(time.Since(cw.start).Microseconds()) / 1000,
+		ResponseSize: cw.size,
+		Truncated:    cw.truncated,
+	}
+
+	record.rawQuery = r.URL.RawQuery
+	record.rawRequestHeaders = r.Header.Clone()
+	record.rawResponseBody = append([]byte{}, cw.body.Bytes()...)
+	if cw.requestBody != nil {
+		record.RequestSize = cw.requestBody.size
+		record.rawRequestBody = append([]byte{}, cw.requestBody.buf.Bytes()...)
+		record.requestTruncated = cw.requestBody.truncated
+		if ri.config.CaptureBodies {
+			record.Truncated = record.Truncated || cw.requestBody.truncated
+		}
+	}
+
+	if ri.config.CaptureHeaders {
+		record.RequestHeaders = ri.redactHeaders(r.Header)
+		record.ResponseHeaders = ri.redactHeaders(cw.Header())
+	}
+
+	if ri.config.CaptureBodies {
+		if cw.requestBody != nil {
+			record.RequestBody = ri.redactBody(cw.requestBody.buf.String())
+		}
+		record.ResponseBody = ri.redactBody(cw.body.String())
+	}
+
+	record = ri.add(record)
+
+	if ri.config.AccessLog {
+		ri.logRequest(record)
+	}
+
+	return record
+}
+
+// captureReader counts request body bytes and keeps a copy up to limit
+type captureReader struct {
+	io.ReadCloser
+	limit     int
+	size      int64
+	buf       bytes.Buffer
+	truncated bool
+}
+
+// Read reads from the underlying body while recording what was read
+func (cr *captureReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.size += int64(n)
+
+	if cr.limit > 0 && n > 0 {
+		remaining := cr.limit - cr.buf.Len()
+		if remaining >= n {
+			cr.buf.Write(p[:n])
+		} else {
+			if remaining > 0 {
+				cr.buf.Write(p[:remaining])
+			}
+			cr.truncated = true
+		}
+	}
+
+	return n, err
+}
+
+// formatSize formats a byte count for display
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+// wantsJSON checks if a request prefers a JSON response over HTML
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || !strings.Contains(r.Header.Get("Accept"), "text/html")
+}
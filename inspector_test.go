@@ -0,0 +1,181 @@
+package godevwatch
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestInspectorRingBuffer(t *testing.T) {
+	ri := NewRequestInspector(InspectorConfig{BufferSize: 3})
+	for i := 0; i < 5; i++ {
+		ri.add(RequestRecord{Path: "/"})
+	}
+
+	var ids []int64
+	for _, record := range ri.Records() {
+		ids = append(ids, record.ID)
+	}
+	if len(ids) != 3 || ids[0] != 5 || ids[1] != 4 || ids[2] != 3 {
+		t.Fatalf("record IDs = %v, want [5 4 3]", ids)
+	}
+
+	tests := []struct {
+		id    int64
+		found bool
+	}{
+		{1, false},
+		{3, true},
+		{5, true},
+		{6, false},
+	}
+	for _, tt := range tests {
+		if _, found := ri.Get(tt.id); found != tt.found {
+			t.Errorf("Get(%d) found = %v, want %v", tt.id, found, tt.found)
+		}
+	}
+
+	ri.Clear()
+	if records := ri.Records(); len(records) != 0 {
+		t.Fatalf("records after Clear = %v", records)
+	}
+	if record := ri.add(RequestRecord{}); record.ID != 6 {
+		t.Fatalf("ID after Clear = %d, want 6", record.ID)
+	}
+}
+
+func TestRequestInspectorRedact(t *testing.T) {
+	ri := NewRequestInspector(InspectorConfig{Redact: []string{"Authorization", "password", "token"}})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"json field", `{"user":"bob","password":"hunter2"}`, `{"user":"bob","password":"[REDACTED]"}`},
+		{"json escaped quote", `{"password": "a\"b", "x": 1}`, `{"password": "[REDACTED]", "x": 1}`},
+		{"json case", `{"Token":"abc"}`, `{"Token":"[REDACTED]"}`},
+		{"form field", `user=bob&password=hunter2&remember=1`, `user=bob&password=[REDACTED]&remember=1`},
+		{"query first field", `token=abc&page=2`, `token=[REDACTED]&page=2`},
+		{"similar name kept", `mytoken=abc`, `mytoken=abc`},
+		{"nothing sensitive", `{"user":"bob"}`, `{"user":"bob"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ri.redactBody(tt.body); got != tt.want {
+				t.Fatalf("redactBody = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	header := http.Header{"Authorization": {"Bearer abc"}, "Accept": {"text/html"}}
+	redacted := ri.redactHeaders(header)
+	if redacted.Get("Authorization") != redactedValue || redacted.Get("Accept") != "text/html" {
+		t.Fatalf("redactHeaders = %v", redacted)
+	}
+	if header.Get("Authorization") != "Bearer abc" {
+		t.Fatal("redactHeaders modified the original header")
+	}
+}
+
+func TestCaptureWriter(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       InspectorConfig
+		status       int
+		response     string
+		wantStatus   int
+		wantRequest  string
+		wantResponse string
+		wantHeaders  bool
+		truncated    bool
+	}{
+		{
+			name:       "metadata only",
+			config:     InspectorConfig{},
+			response:   "hello",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "bodies and headers",
+			config:       InspectorConfig{CaptureBodies: true, CaptureHeaders: true, Redact: []string{"password"}},
+			status:       http.StatusCreated,
+			response:     `{"ok":true}`,
+			wantStatus:   http.StatusCreated,
+			wantRequest:  `{"password":"[REDACTED]"}`,
+			wantResponse: `{"ok":true}`,
+			wantHeaders:  true,
+		},
+		{
+			name:         "truncated bodies",
+			config:       InspectorConfig{CaptureBodies: true, MaxBodySize: 4},
+			response:     "hello world",
+			wantStatus:   http.StatusOK,
+			wantRequest:  `{"pa`,
+			wantResponse: "hell",
+			truncated:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ri := NewRequestInspector(tt.config)
+			r := httptest.NewRequest(http.MethodPost, "/login?next=/home", strings.NewReader(`{"password":"hunter2"}`))
+			w := httptest.NewRecorder()
+
+			cw := ri.Capture(w, r)
+			io.ReadAll(r.Body)
+			cw.SetBackend("api")
+			if tt.status != 0 {
+				cw.WriteHeader(tt.status)
+			}
+			io.WriteString(cw, tt.response)
+			record := cw.Finish()
+
+			if w.Body.String() != tt.response {
+				t.Errorf("client received %q", w.Body.String())
+			}
+			if record.Status != tt.wantStatus || record.Backend != "api" || record.Query != "next=/home" {
+				t.Errorf("record = %+v", record)
+			}
+			if record.RequestSize != 22 || record.ResponseSize != int64(len(tt.response)) {
+				t.Errorf("sizes = %d/%d", record.RequestSize, record.ResponseSize)
+			}
+			if record.RequestBody != tt.wantRequest || record.ResponseBody != tt.wantResponse {
+				t.Errorf("bodies = %q / %q, want %q / %q", record.RequestBody, record.ResponseBody, tt.wantRequest, tt.wantResponse)
+			}
+			if (record.RequestHeaders != nil) != tt.wantHeaders {
+				t.Errorf("request headers = %v", record.RequestHeaders)
+			}
+			if record.Truncated != tt.truncated {
+				t.Errorf("truncated = %v, want %v", record.Truncated, tt.truncated)
+			}
+
+			// The unredacted request is kept for replays, up to the body limit
+			if !tt.truncated && string(record.rawRequestBody) != `{"password":"hunter2"}` {
+				t.Errorf("raw request body = %q", record.rawRequestBody)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.5KB"},
+		{3 * 1024 * 1024, "3.0MB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package godevwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// logFollowBuffer is how many lines may be queued for a follower before new ones are dropped
+const logFollowBuffer = 256
+
+// LogLine is a line of output from the application or another managed process
+type LogLine struct {
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	BuildID string    `json:"build_id"`
+	PID     int       `json:"pid"`
+	Stream  string    `json:"stream"`
+	Level   string    `json:"level,omitempty"`
+	Line    string    `json:"line"`
+}
+
+// LogBuffer keeps the most recent output of the current application run in a
+// fixed-size ring buffer and fans new lines out to followers
+type LogBuffer struct {
+	lines     []LogLine
+	next      int
+	count     int
+	seq       int64
+	followers map[chan LogLine]bool
+	mu        sync.Mutex
+}
+
+// NewLogBuffer creates a log buffer holding up to size lines
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = 1000
+	}
+	return &LogBuffer{
+		lines:     make([]LogLine, size),
+		followers: make(map[chan LogLine]bool),
+	}
+}
+
+// Reset discards the buffered lines when a new run starts
+func (lb *LogBuffer) Reset() {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.next = 0
+	lb.count = 0
+}
+
+// Add stores a line, overwriting the oldest when full, and sends it to followers
+func (lb *LogBuffer) Add(line LogLine) LogLine {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.seq++
+	line.Seq = lb.seq
+	lb.lines[lb.next] = line
+	lb.next = (lb.next + 1) % len(lb.lines)
+	if lb.count < len(lb.lines) {
+		lb.count++
+	}
+
+	for ch := range lb.followers {
+		select {
+		case ch <- line:
+		default:
+			// Follower is not keeping up, drop the line
+		}
+	}
+
+	return line
+}
+
+// Lines returns the buffered lines, oldest first
+func (lb *LogBuffer) Lines() []LogLine {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.tail(lb.count)
+}
+
+// Tail returns up to the last n buffered lines, oldest first
+func (lb *LogBuffer) Tail(n int) []LogLine {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.tail(min(n, lb.count))
+}
+
+// tail returns the last n lines, the caller must hold mu
+func (lb *LogBuffer) tail(n int) []LogLine {
+	lines := make([]LogLine, 0, n)
+	for i := n; i >= 1; i-- {
+		idx := (lb.next - i + len(lb.lines)) % len(lb.lines)
+		lines = append(lines, lb.lines[idx])
+	}
+	return lines
+}
+
+// Follow returns the buffered lines and a channel of lines added afterwards,
+// plus a function that stops following
+func (lb *LogBuffer) Follow() ([]LogLine, <-chan LogLine, func()) {
+	ch := make(chan LogLine, logFollowBuffer)
+
+	lb.mu.Lock()
+	lines := lb.tail(lb.count)
+	lb.followers[ch] = true
+	lb.mu.Unlock()
+
+	var once sync.Once
+	return lines, ch, func() {
+		once.Do(func() {
+			lb.mu.Lock()
+			delete(lb.followers, ch)
+			lb.mu.Unlock()
+		})
+	}
+}
+
+// String formats a line for plain text output
+func (l LogLine) String() string {
+	return fmt.Sprintf("%s %s %s", l.Time.Format("15:04:05.000"), l.Stream, l.Line)
+}
+
+// handleLogs serves the application's buffered output as text or JSON, and
+// streams new lines with ?follow. ?process selects another managed process.
+func (ps *ProxyServer) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if ps.fileWatcher == nil {
+		http.Error(w, "File watching is disabled", http.StatusConflict)
+		return
+	}
+
+	logs := ps.fileWatcher.Logs()
+	if name := r.URL.Query().Get("process"); name != "" {
+		var ok bool
+		if logs, ok = ps.fileWatcher.ProcessLogs(name); !ok {
+			http.Error(w, fmt.Sprintf("Unknown process %q", name), http.StatusNotFound)
+			return
+		}
+	}
+
+	asJSON := r.URL.Query().Get("format") == "json"
+	_, follow := r.URL.Query()["follow"]
+
+	if !follow {
+		lines := logs.Lines()
+		if asJSON {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(lines)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+		return
+	}
+
+	lines, ch, stop := logs.Follow()
+	defer stop()
+
+	// Newline-delimited JSON when following so each line can be parsed on arrival
+	write := func(line LogLine) {
+		if asJSON {
+			json.NewEncoder(w).Encode(line)
+		} else {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	rc := http.NewResponseController(w)
+	for _, line := range lines {
+		write(line)
+	}
+	rc.Flush()
+
+	for {
+		select {
+		case line := <-ch:
+			write(line)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package godevwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logText returns the text of each line
+func logText(lines []LogLine) string {
+	var text []string
+	for _, line := range lines {
+		text = append(text, line.Line)
+	}
+	return fmt.Sprint(text)
+}
+
+func TestLogBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		add       int
+		tail      int
+		wantLines string
+		wantTail  string
+	}{
+		{"empty", 3, 0, 2, "[]", "[]"},
+		{"partly filled", 3, 2, 5, "[0 1]", "[0 1]"},
+		{"full", 3, 3, 2, "[0 1 2]", "[1 2]"},
+		{"wrapped", 3, 5, 2, "[2 3 4]", "[3 4]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLogBuffer(tt.size)
+			for i := 0; i < tt.add; i++ {
+				if line := lb.Add(LogLine{Line: fmt.Sprint(i)}); line.Seq != int64(i+1) {
+					t.Fatalf("seq = %d, want %d", line.Seq, i+1)
+				}
+			}
+			if got := logText(lb.Lines()); got != tt.wantLines {
+				t.Errorf("Lines = %s, want %s", got, tt.wantLines)
+			}
+			if got := logText(lb.Tail(tt.tail)); got != tt.wantTail {
+				t.Errorf("Tail(%d) = %s, want %s", tt.tail, got, tt.wantTail)
+			}
+		})
+	}
+
+	// A new run starts empty but keeps numbering lines
+	lb := NewLogBuffer(0)
+	lb.Add(LogLine{Line: "old"})
+	lb.Reset()
+	if line := lb.Add(LogLine{Line: "new"}); line.Seq != 2 || logText(lb.Lines()) != "[new]" {
+		t.Fatalf("after Reset: %+v, lines %s", line, logText(lb.Lines()))
+	}
+}
+
+func TestLogBufferFollow(t *testing.T) {
+	lb := NewLogBuffer(10)
+	lb.Add(LogLine{Line: "before"})
+
+	lines, ch, stop := lb.Follow()
+	if logText(lines) != "[before]" {
+		t.Fatalf("buffered lines = %s", logText(lines))
+	}
+
+	lb.Add(LogLine{Line: "after"})
+	select {
+	case line := <-ch:
+		if line.Line != "after" {
+			t.Fatalf("followed %q", line.Line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new line not sent to follower")
+	}
+
+	// Stopping twice is safe and no more lines are sent
+	stop()
+	stop()
+	lb.Add(LogLine{Line: "stopped"})
+	if len(ch) != 0 {
+		t.Fatal("line sent after stop")
+	}
+
+	// A slow follower drops lines instead of blocking the process
+	_, slow, stopSlow := lb.Follow()
+	defer stopSlow()
+	for i := 0; i < logFollowBuffer+10; i++ {
+		lb.Add(LogLine{Line: "flood"})
+	}
+	if len(slow) != logFollowBuffer {
+		t.Fatalf("follower queued %d lines, want %d", len(slow), logFollowBuffer)
+	}
+}
+
+func TestHandleLogs(t *testing.T) {
+	fw, _ := newTestWatcher(t)
+	fw.Logs().Add(LogLine{Stream: "stdout", Line: "listening", Time: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)})
+
+	tests := []struct {
+		name       string
+		watcher    *FileWatcher
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"text", fw, "", http.StatusOK, "15:04:05.000 stdout listening\n"},
+		{"json", fw, "?format=json", http.StatusOK, `"line":"listening"`},
+		{"unknown process", fw, "?process=worker", http.StatusNotFound, `Unknown process "worker"`},
+		{"watching disabled", nil, "", http.StatusConflict, "File watching is disabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := &ProxyServer{fileWatcher: tt.watcher}
+			w := httptest.NewRecorder()
+			ps.handleLogs(w, httptest.NewRequest(http.MethodGet, "/.godevwatch/logs"+tt.query, nil))
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Fatalf("response = %d %q, want %d %q", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	// Following sends the buffered lines, then new ones as they arrive
+	server := httptest.NewServer(http.HandlerFunc((&ProxyServer{fileWatcher: fw}).handleLogs))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?follow&format=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	var line LogLine
+	if err := decoder.Decode(&line); err != nil || line.Line != "listening" {
+		t.Fatalf("first line = %+v, %v", line, err)
+	}
+	fw.Logs().Add(LogLine{Line: "request served"})
+	if err := decoder.Decode(&line); err != nil || line.Line != "request served" {
+		t.Fatalf("followed line = %+v, %v", line, err)
+	}
+}
@@ -0,0 +1,501 @@
+package godevwatch
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// appProcessName is the name of the process started from run_cmd
+	appProcessName = "app"
+
+	// defaultReadyTimeout is how long to wait for a ready check without a timeout
+	defaultReadyTimeout = 30 * time.Second
+
+	// appOutputRate is the most output lines of a process forwarded to clients per second
+	appOutputRate = 100
+)
+
+// processColors are the ANSI colors used to label managed process output
+var processColors = []string{"\033[34m", "\033[35m", "\033[36m", "\033[32m", "\033[33m", "\033[94m", "\033[95m", "\033[96m"}
+
+// managedProcess runs a command, restarts it according to its restart policy
+// and records its output
+type managedProcess struct {
+	config       ProcessConfig
+	port         int
+	color        string
+	events       *EventBus
+	logs         *LogBuffer
+	formatter    *LogFormatter
+	readyLog     *regexp.Regexp
+	cmd          *Command
+	started      chan struct{}
+	ready        chan struct{}
+	exited       chan struct{}
+	exitCode     int
+	restartTimer *time.Timer
+	attempts     int
+	mu           sync.Mutex
+}
+
+// newManagedProcess creates a managed process from its configuration
+func newManagedProcess(config ProcessConfig, color string, events *EventBus, formatter *LogFormatter, logSize int) (*managedProcess, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("process name is required")
+	}
+	if err := config.RestartPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("process %q: %w", config.Name, err)
+	}
+	if err := config.Stop.Validate(); err != nil {
+		return nil, fmt.Errorf("process %q: %w", config.Name, err)
+	}
+
+	mp := &managedProcess{
+		config:    config,
+		color:     color,
+		events:    events,
+		logs:      NewLogBuffer(logSize),
+		formatter: formatter,
+	}
+
+	if config.Ready.Log != "" {
+		re, err := regexp.Compile(config.Ready.Log)
+		if err != nil {
+			return nil, fmt.Errorf("process %q: invalid ready.log pattern: %w", config.Name, err)
+		}
+		mp.readyLog = re
+	}
+
+	return mp, nil
+}
+
+// Name returns the process name
+func (mp *managedProcess) Name() string {
+	return mp.config.Name
+}
+
+// Start launches the process with a fresh set of restart attempts, stopping
+// a running one first
+func (mp *managedProcess) Start(buildID string) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.cancelRestart()
+	mp.attempts = 0
+	if mp.cmd != nil {
+		// Clear the command first so its exit is not treated as a crash
+		cmd := mp.cmd
+		mp.cmd = nil
+		cmd.Kill()
+	}
+	mp.start(buildID)
+}
+
+// Replace starts the process on a new port while the current one keeps running,
+// returning the command it replaced so it can be stopped later and a channel
+// closed when the new process exits
+func (mp *managedProcess) Replace(buildID string, port int) (*Command, <-chan struct{}) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.cancelRestart()
+	mp.attempts = 0
+	previous := mp.cmd
+	mp.port = port
+	mp.start(buildID)
+	return previous, mp.exited
+}
+
+// Restore makes a replaced command current again after its replacement failed,
+// stopping the replacement
+func (mp *managedProcess) Restore(previous *Command, port int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.cancelRestart()
+	failed := mp.cmd
+	mp.cmd = previous
+	mp.port = port
+	if previous.Exited() {
+		// The previous process exited while its replacement was starting
+		mp.cmd = nil
+	}
+	if failed != nil {
+		failed.Kill()
+	}
+}
+
+// Stop cancels any pending restart and kills the process, waiting for it to exit
+func (mp *managedProcess) Stop() error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.cancelRestart()
+	if mp.cmd == nil {
+		return nil
+	}
+
+	// Clear the command first so its exit is not treated as a crash
+	cmd := mp.cmd
+	mp.cmd = nil
+	return cmd.Kill()
+}
+
+// Running reports whether the process has been started and not stopped
+func (mp *managedProcess) Running() bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.cmd != nil
+}
+
+// current returns the running command and a channel closed when it exits
+func (mp *managedProcess) current() (*Command, <-chan struct{}) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.cmd, mp.exited
+}
+
+// start launches the process, the caller must hold mu
+func (mp *managedProcess) start(buildID string) {
+	name := mp.config.Name
+	pid := 0
+	var started time.Time
+
+	// Keep only this run's output
+	mp.logs.Reset()
+	startedCh := make(chan struct{})
+	ready := make(chan struct{})
+	exited := make(chan struct{})
+	mp.started = startedCh
+	mp.ready = ready
+	mp.exited = exited
+	var readyOnce sync.Once
+	markReady := func() { readyOnce.Do(func() { close(ready) }) }
+
+	cmd := NewCommand(mp.config.Command)
+	cmd.Name = name
+	cmd.Dir = mp.config.Dir
+	cmd.Stop = mp.config.Stop
+	cmd.Env = mp.environ()
+	limiter := newOutputLimiter(appOutputRate, func(dropped int) {
+		mp.events.Publish(MessageAppOutput, AppOutputData{
+			Process: name,
+			BuildID: buildID,
+			PID:     pid,
+			Stream:  "stderr",
+			Line:    skippedLine(dropped),
+			Dropped: dropped,
+		})
+	})
+	output := func(stream, line string) {
+		mp.output(LogLine{Time: time.Now(), BuildID: buildID, PID: pid, Stream: stream, Line: line}, limiter)
+		if mp.readyLog != nil && mp.readyLog.MatchString(line) {
+			markReady()
+		}
+	}
+	cmd.OnStdout = func(line string) { output("stdout", line) }
+	cmd.OnStderr = func(line string) { output("stderr", line) }
+	cmd.OnStart = func(p int) {
+		pid = p
+		started = time.Now()
+		close(startedCh)
+		mp.events.Publish(MessageAppStarted, AppStartedData{
+			Process: name,
+			BuildID: buildID,
+			PID:     pid,
+			Command: mp.config.Command,
+		})
+	}
+
+	mp.cmd = cmd
+
+	// Run in background
+	go func() {
+		err := cmd.Run()
+		limiter.Flush()
+		mp.mu.Lock()
+		mp.exitCode = cmd.ExitCode()
+		mp.mu.Unlock()
+		close(exited)
+
+		exitedData := AppExitedData{
+			Process:  name,
+			BuildID:  buildID,
+			PID:      pid,
+			ExitCode: cmd.ExitCode(),
+		}
+		if err != nil {
+			log.Printf("\033[33m[%s] %s exited: %v\033[0m\n", buildID, name, err)
+			exitedData.Error = err.Error()
+		}
+		mp.events.Publish(MessageAppExited, exitedData)
+
+		// A command that failed to start had no uptime
+		var uptime time.Duration
+		if !started.IsZero() {
+			uptime = time.Since(started)
+		}
+		mp.handleExit(cmd, exitedData, uptime)
+	}()
+}
+
+// environ returns the configured environment variables in a stable order,
+// and PORT when the process is given a port to listen on
+func (mp *managedProcess) environ() []string {
+	keys := make([]string, 0, len(mp.config.Env))
+	for key := range mp.config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, key+"="+mp.config.Env[key])
+	}
+	if mp.port != 0 {
+		env = append(env, fmt.Sprintf("PORT=%d", mp.port))
+	}
+	return env
+}
+
+// handleExit reports an unexpected exit and schedules a restart according to the restart policy
+func (mp *managedProcess) handleExit(cmd *Command, exited AppExitedData, uptime time.Duration) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	// Exits caused by stopping the process are expected
+	if mp.cmd != cmd {
+		return
+	}
+	mp.cmd = nil
+
+	name := mp.config.Name
+	signal := cmd.Signal()
+	crashed := exited.ExitCode != 0 || signal != ""
+	policy := mp.config.RestartPolicy
+
+	// A process that stayed up for a while is not crash looping
+	if uptime >= crashResetAfter {
+		mp.attempts = 0
+	}
+
+	crash := AppCrashData{
+		Process:  name,
+		BuildID:  exited.BuildID,
+		PID:      exited.PID,
+		ExitCode: exited.ExitCode,
+		Signal:   signal,
+		Error:    exited.Error,
+		Output:   []string{},
+	}
+	for _, line := range mp.logs.Tail(crashOutputLines) {
+		crash.Output = append(crash.Output, line.Line)
+	}
+
+	if crashed {
+		if signal != "" {
+			log.Printf("\033[31m[%s] %s crashed: %s\033[0m\n", exited.BuildID, name, signal)
+		} else {
+			log.Printf("\033[31m[%s] %s crashed with exit code %d\033[0m\n", exited.BuildID, name, exited.ExitCode)
+		}
+	}
+
+	if policy.ShouldRestart(crashed) {
+		mp.attempts++
+		crash.Attempt = mp.attempts
+		if policy.MaxRetries > 0 && mp.attempts > policy.MaxRetries {
+			crash.CrashLoop = true
+			log.Printf("\033[31m[%s] %s is crash looping, giving up after %d restarts\033[0m\n", exited.BuildID, name, policy.MaxRetries)
+		} else {
+			delay := policy.Delay(mp.attempts)
+			crash.Restarting = true
+			crash.RetryInMs = float64(delay.Milliseconds())
+			log.Printf("\033[33m[%s] Restarting %s in %s (attempt %d)\033[0m\n", exited.BuildID, name, delay, mp.attempts)
+			mp.scheduleRestart(exited.BuildID, delay)
+		}
+	}
+
+	if crashed {
+		mp.events.Publish(MessageAppCrash, crash)
+	}
+}
+
+// scheduleRestart starts the process again after delay unless it is stopped or restarted first
+func (mp *managedProcess) scheduleRestart(buildID string, delay time.Duration) {
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		mp.mu.Lock()
+		defer mp.mu.Unlock()
+
+		if mp.restartTimer != timer {
+			return
+		}
+		mp.restartTimer = nil
+		mp.start(buildID)
+	})
+	mp.restartTimer = timer
+}
+
+// cancelRestart stops a pending restart, the caller must hold mu
+func (mp *managedProcess) cancelRestart() {
+	if mp.restartTimer != nil {
+		mp.restartTimer.Stop()
+		mp.restartTimer = nil
+	}
+}
+
+// output prints a line of process output with a colored label and records the
+// raw line, publishing it to clients unless limiter drops it
+func (mp *managedProcess) output(line LogLine, limiter *outputLimiter) {
+	formatted, level, show := mp.formatter.Format(line.Line)
+	line.Level = level
+
+	if show {
+		if line.Stream == "stderr" {
+			fmt.Fprintf(os.Stderr, "\033[31m[%s]\033[0m %s\n", mp.config.Name, formatted)
+		} else {
+			fmt.Fprintf(os.Stdout, "%s[%s]\033[0m %s\n", mp.color, mp.config.Name, formatted)
+		}
+	}
+
+	mp.logs.Add(line)
+	if !limiter.Allow() {
+		return
+	}
+	mp.events.Publish(MessageAppOutput, AppOutputData{
+		Process: mp.config.Name,
+		BuildID: line.BuildID,
+		PID:     line.PID,
+		Stream:  line.Stream,
+		Line:    line.Line,
+		Level:   line.Level,
+	})
+}
+
+// WaitReady blocks until the ready check passes, the process exits or the ready timeout expires
+func (mp *managedProcess) WaitReady() error {
+	timeout := mp.config.Ready.Timeout
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+	return mp.waitReady(timeout)
+}
+
+// WaitFor blocks until the process reaches a depends_on condition or the timeout expires
+func (mp *managedProcess) WaitFor(condition string, timeout time.Duration) error {
+	switch condition {
+	case ConditionHealthy:
+		return mp.waitReady(timeout)
+	case ConditionCompletedSuccessfully:
+		mp.mu.Lock()
+		exited := mp.exited
+		mp.mu.Unlock()
+		if exited == nil {
+			return fmt.Errorf("%s has not been started", mp.config.Name)
+		}
+
+		select {
+		case <-exited:
+		case <-time.After(timeout):
+			return fmt.Errorf("%s did not complete within %s", mp.config.Name, timeout)
+		}
+
+		mp.mu.Lock()
+		code := mp.exitCode
+		mp.mu.Unlock()
+		if code != 0 {
+			return fmt.Errorf("%s exited with code %d", mp.config.Name, code)
+		}
+		return nil
+	default:
+		mp.mu.Lock()
+		started, exited := mp.started, mp.exited
+		mp.mu.Unlock()
+		if started == nil {
+			return fmt.Errorf("%s has not been started", mp.config.Name)
+		}
+
+		select {
+		case <-started:
+			return nil
+		case <-exited:
+			return fmt.Errorf("%s exited before starting", mp.config.Name)
+		case <-time.After(timeout):
+			return fmt.Errorf("%s did not start within %s", mp.config.Name, timeout)
+		}
+	}
+}
+
+// waitReady blocks until the ready check passes, the process exits or the timeout expires
+func (mp *managedProcess) waitReady(timeout time.Duration) error {
+	check := mp.config.Ready
+	if check.HTTP == "" && check.TCP == "" && check.Log == "" {
+		return nil
+	}
+
+	mp.mu.Lock()
+	ready, exited := mp.ready, mp.exited
+	mp.mu.Unlock()
+	if ready == nil {
+		return fmt.Errorf("%s has not been started", mp.config.Name)
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if check.Log == "" && mp.probe() {
+			return nil
+		}
+
+		select {
+		case <-ready:
+			if check.HTTP == "" && check.TCP == "" {
+				return nil
+			}
+			// The log line matched, keep probing the network check
+			ready = nil
+		case <-exited:
+			return fmt.Errorf("%s exited before becoming ready", mp.config.Name)
+		case <-deadline:
+			return fmt.Errorf("%s did not become ready within %s", mp.config.Name, timeout)
+		case <-ticker.C:
+		}
+
+		if check.Log != "" && ready == nil && mp.probe() {
+			return nil
+		}
+	}
+}
+
+// probe runs the HTTP or TCP ready check once
+func (mp *managedProcess) probe() bool {
+	check := mp.config.Ready
+	if check.HTTP != "" {
+		client := &http.Client{Timeout: time.Second}
+		resp, err := client.Get(check.HTTP)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode < 500
+	}
+	if check.TCP != "" {
+		conn, err := net.DialTimeout("tcp", check.TCP, time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	return true
+}
@@ -1,81 +1,236 @@
 package godevwatch
 
 import (
+	"fmt"
 	"log"
-	"sync"
 )
 
 // ProcessManager manages the build and run process lifecycle
 type ProcessManager struct {
 	config       *Config
 	buildTracker *BuildTracker
-	currentCmd   *Command
-	mu           sync.Mutex
+	app          *managedProcess
+	services     []*managedProcess
+	upstream     Upstream
+	retired      *Command
 }
 
 // NewProcessManager creates a new process manager
-func NewProcessManager(config *Config, buildTracker *BuildTracker) *ProcessManager {
-	return &ProcessManager{
+func NewProcessManager(config *Config, buildTracker *BuildTracker) (*ProcessManager, error) {
+	formatter, err := NewLogFormatter(config.Logs)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.RestartPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Swap.Validate(config.BackendPort); err != nil {
+		return nil, err
+	}
+
+	events := buildTracker.Events()
+	app, err := newManagedProcess(ProcessConfig{
+		Name:          appProcessName,
+		Command:       config.RunCmd,
+		RestartPolicy: config.RestartPolicy,
+		Stop:          config.Stop,
+	}, processColors[0], events, formatter, config.Logs.BufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Swapped apps are told which port to listen on
+	if config.Swap.Enabled {
+		app.port = config.BackendPort
+	}
+
+	pm := &ProcessManager{
 		config:       config,
 		buildTracker: buildTracker,
+		app:          app,
+	}
+
+	names := map[string]bool{appProcessName: true}
+	colors := make(map[string]string, len(config.Processes))
+	for i, pc := range config.Processes {
+		if names[pc.Name] {
+			return nil, fmt.Errorf("duplicate process name %q", pc.Name)
+		}
+		names[pc.Name] = true
+		if pc.Command == "" {
+			return nil, fmt.Errorf("process %q: command is required", pc.Name)
+		}
+		colors[pc.Name] = processColors[(i+1)%len(processColors)]
+	}
+
+	// Start each process after the processes it depends on
+	ordered, err := validateDependencies(config.Processes, config.DependsOn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pc := range ordered {
+		service, err := newManagedProcess(pc, colors[pc.Name], events, formatter, config.Logs.BufferSize)
+		if err != nil {
+			return nil, err
+		}
+		pm.services = append(pm.services, service)
+	}
+
+	return pm, nil
+}
+
+// Logs returns the buffered output of the current application run
+func (pm *ProcessManager) Logs() *LogBuffer {
+	return pm.app.logs
+}
+
+// service returns the named process, or nil if there is none
+func (pm *ProcessManager) service(name string) *managedProcess {
+	for _, service := range pm.services {
+		if service.Name() == name {
+			return service
+		}
+	}
+	return nil
+}
+
+// ProcessLogs returns the buffered output of the named process
+func (pm *ProcessManager) ProcessLogs(name string) (*LogBuffer, bool) {
+	if name == appProcessName {
+		return pm.app.logs, true
+	}
+	if service := pm.service(name); service != nil {
+		return service.logs, true
+	}
+	return nil, false
+}
+
+// StartServices starts the configured processes in dependency order, waiting for each to be ready
+func (pm *ProcessManager) StartServices() {
+	for _, service := range pm.services {
+		pm.startService(service, "startup")
+	}
+}
+
+// RestartServices restarts the processes that restart on build, in order
+func (pm *ProcessManager) RestartServices(buildID string) {
+	for _, service := range pm.services {
+		if !service.config.RestartOnBuild {
+			continue
+		}
+		service.Stop()
+		pm.startService(service, buildID)
 	}
 }
 
+// startService starts a process once its dependencies are met and waits for its ready check
+func (pm *ProcessManager) startService(service *managedProcess, buildID string) {
+	if err := pm.waitDependencies(service.Name(), service.config.DependsOn, buildID); err != nil {
+		log.Printf("\033[31m[%s] Not starting %s: %v\033[0m\n", buildID, service.Name(), err)
+		return
+	}
+
+	log.Printf("\033[36m[%s] Starting %s...\033[0m\n", buildID, service.Name())
+	service.Start(buildID)
+	if err := service.WaitReady(); err != nil {
+		log.Printf("\033[31m[%s] %v\033[0m\n", buildID, err)
+		return
+	}
+	if check := service.config.Ready; check.HTTP == "" && check.TCP == "" && check.Log == "" {
+		log.Printf("\033[32m[%s] %s started\033[0m\n", buildID, service.Name())
+		return
+	}
+	log.Printf("\033[32m[%s] %s ready\033[0m\n", buildID, service.Name())
+}
+
 // StopCurrentProcess stops any currently running application process
 func (pm *ProcessManager) StopCurrentProcess(buildID string) error {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
 	// Kill any currently running process and WAIT for it to terminate
-	if pm.currentCmd != nil {
+	if pm.app.Running() {
 		log.Printf("\033[33m[%s] Stopping previous process...\033[0m\n", buildID)
-		if err := pm.currentCmd.Kill(); err != nil {
+		if err := pm.app.Stop(); err != nil {
 			// Only log if it's not already finished
 			if err.Error() != "os: process already finished" {
 				log.Printf("Warning: Error killing process: %v", err)
 			}
 		}
-		pm.currentCmd = nil
 		log.Printf("\033[33m[%s] Previous process stopped\033[0m\n", buildID)
+	} else {
+		// Cancel any pending crash restart
+		pm.app.Stop()
 	}
 
 	return nil
 }
 
-// RunProcess runs the application process (assumes previous process already stopped)
-func (pm *ProcessManager) RunProcess(buildID string) error {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
+// AppRunning reports whether the application has been started and not stopped
+func (pm *ProcessManager) AppRunning() bool {
+	return pm.app.Running()
+}
 
-	// Create new command
-	cmd := NewCommand(pm.config.RunCmd)
-	cmd.OnStdout = func(line string) {
-		log.Println(line)
-	}
-	cmd.OnStderr = func(line string) {
-		log.Println(line)
+// RunProcess runs the application process once its dependencies are met
+// (assumes previous process already stopped)
+func (pm *ProcessManager) RunProcess(buildID string) error {
+	if err := pm.waitDependencies(appProcessName, pm.config.DependsOn, buildID); err != nil {
+		return err
 	}
 
-	pm.currentCmd = cmd
+	// A new build gets a fresh set of restart attempts
+	pm.app.Start(buildID)
+	return nil
+}
+
+// ReloadWhenReady reloads browsers once the app started for a build accepts
+// requests, so they neither load the previous app nor land in the restart gap
+func (pm *ProcessManager) ReloadWhenReady(buildID string) {
+	cmd, exited := pm.app.current()
+	if pm.upstream == nil || cmd == nil {
+		pm.publishReload()
+		return
+	}
 
-	// Run in background
 	go func() {
-		if err := cmd.Run(); err != nil {
-			log.Printf("\033[33m[%s] Application exited: %v\033[0m\n", buildID, err)
+		if err := pm.waitUpstream(pm.upstream.UpstreamPort(), cmd, exited); err != nil {
+			log.Printf("\033[33m[%s] Not reloading browsers: %v\033[0m\n", buildID, err)
+			return
 		}
+		// A later build may have replaced the app in the meantime
+		if current, _ := pm.app.current(); current != cmd {
+			return
+		}
+		pm.publishReload()
 	}()
+}
 
-	return nil
+// publishReload tells clients the rebuilt app is serving
+func (pm *ProcessManager) publishReload() {
+	pm.buildTracker.Events().Publish(MessageReload, ReloadData{Reason: "build-succeeded"})
+}
+
+// Restart stops the running application and starts it again without rebuilding
+func (pm *ProcessManager) Restart(buildID string) error {
+	if err := pm.StopCurrentProcess(buildID); err != nil {
+		return err
+	}
+	return pm.RunProcess(buildID)
 }
 
-// Stop stops all managed processes
+// Stop stops all managed processes, the app first and then the services in reverse order
 func (pm *ProcessManager) Stop() error {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
+	err := pm.app.Stop()
 
-	if pm.currentCmd != nil {
-		return pm.currentCmd.Kill()
+	for i := len(pm.services) - 1; i >= 0; i-- {
+		service := pm.services[i]
+		if !service.Running() {
+			service.Stop()
+			continue
+		}
+		log.Printf("\033[33mStopping %s...\033[0m\n", service.Name())
+		if stopErr := service.Stop(); stopErr != nil && err == nil {
+			err = stopErr
+		}
 	}
 
-	return nil
+	return err
 }
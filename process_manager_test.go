@@ -0,0 +1,75 @@
+package godevwatch
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestProcessManager creates a process manager for runCmd with a fake upstream
+func newTestProcessManager(t *testing.T, runCmd string) (*ProcessManager, *fakeUpstream, <-chan Event) {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.RunCmd = runCmd
+	config.Swap.ReadyTimeout = 2 * time.Second
+	tracker := NewBuildTracker(t.TempDir())
+	events, unsubscribe := tracker.Events().Subscribe()
+	t.Cleanup(unsubscribe)
+
+	pm, err := NewProcessManager(config, tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &fakeUpstream{dir: t.TempDir(), port: 8080}
+	pm.SetUpstream(upstream)
+	t.Cleanup(func() { pm.StopCurrentProcess("test") })
+	return pm, upstream, events
+}
+
+func TestReloadWhenReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		runCmd   string
+		ready    bool
+		reloaded bool
+	}{
+		{"waits for the app to be ready", "sleep 30", true, true},
+		{"skipped when the app exits first", "exit 1", false, false},
+		{"immediate without an app", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, upstream, events := newTestProcessManager(t, tt.runCmd)
+			if tt.runCmd != "" {
+				if err := pm.RunProcess("test"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pm.ReloadWhenReady("test")
+			reloads := waitFor(events, MessageReload)
+
+			if tt.ready {
+				select {
+				case <-reloads:
+					t.Fatal("reloaded before the app was ready")
+				case <-time.After(300 * time.Millisecond):
+				}
+				app, _ := pm.app.current()
+				writeFile(t, upstream.dir, "ready-8080", strconv.Itoa(app.PID()))
+			}
+
+			select {
+			case <-reloads:
+				if !tt.reloaded {
+					t.Fatal("unexpected reload")
+				}
+			case <-time.After(time.Second):
+				if tt.reloaded {
+					t.Fatal("no reload")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package godevwatch
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProcess creates a managed process publishing to a fresh event bus
+func newTestProcess(t *testing.T, config ProcessConfig) (*managedProcess, <-chan Event) {
+	t.Helper()
+
+	events := NewEventBus()
+	ch, unsubscribe := events.Subscribe()
+	t.Cleanup(unsubscribe)
+
+	formatter, err := NewLogFormatter(LogsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp, err := newManagedProcess(config, processColors[0], events, formatter, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mp.Stop() })
+	return mp, ch
+}
+
+// waitEvent returns the next event of the given type, failing after timeout
+func waitEvent(t *testing.T, ch <-chan Event, msgType MessageType, timeout time.Duration) Event {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event := <-ch:
+			if event.Type == msgType {
+				return event
+			}
+		case <-deadline:
+			t.Fatalf("no %s event within %s", msgType, timeout)
+		}
+	}
+}
+
+func TestManagedProcessFailedStartCountsAsAttempt(t *testing.T) {
+	mp, events := newTestProcess(t, ProcessConfig{
+		Name:    "worker",
+		Command: "true",
+		Dir:     filepath.Join(t.TempDir(), "missing"),
+		RestartPolicy: RestartPolicy{
+			Policy:     RestartOnFailure,
+			MaxRetries: 2,
+			Backoff:    time.Millisecond,
+			MaxBackoff: time.Millisecond,
+		},
+	})
+	mp.Start("test")
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		crash := waitEvent(t, events, MessageAppCrash, 5*time.Second).Data.(AppCrashData)
+		if crash.Attempt != attempt {
+			t.Fatalf("crash %d: attempt = %d", attempt, crash.Attempt)
+		}
+		if crash.CrashLoop != (attempt == 3) {
+			t.Fatalf("crash %d: crash_loop = %v", attempt, crash.CrashLoop)
+		}
+	}
+	if mp.Running() {
+		t.Fatal("process still running after giving up")
+	}
+}
+
+func TestManagedProcessDefaultMaxRetries(t *testing.T) {
+	mp, events := newTestProcess(t, ProcessConfig{
+		Name:          "worker",
+		Command:       "exit 1",
+		RestartPolicy: RestartPolicy{Policy: RestartOnFailure, Backoff: time.Millisecond},
+	})
+	mp.Start("test")
+
+	// Managed processes give up after the same number of restarts as the app
+	for attempt := 1; attempt <= defaultMaxRetries+1; attempt++ {
+		crash := waitEvent(t, events, MessageAppCrash, 5*time.Second).Data.(AppCrashData)
+		if crash.CrashLoop != (attempt == defaultMaxRetries+1) {
+			t.Fatalf("crash %d: crash_loop = %v", attempt, crash.CrashLoop)
+		}
+	}
+}
+
+func TestManagedProcessRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		command   string
+		restarted bool
+	}{
+		{"never after crash", RestartNever, "exit 3", false},
+		{"on-failure after crash", RestartOnFailure, "exit 3", true},
+		{"on-failure after clean exit", RestartOnFailure, "exit 0", false},
+		{"always after clean exit", RestartAlways, "exit 0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp, events := newTestProcess(t, ProcessConfig{
+				Name:    "worker",
+				Command: tt.command,
+				RestartPolicy: RestartPolicy{
+					Policy:     tt.policy,
+					Backoff:    time.Millisecond,
+					MaxBackoff: time.Millisecond,
+				},
+			})
+			mp.Start("test")
+
+			waitEvent(t, events, MessageAppStarted, 5*time.Second)
+			waitEvent(t, events, MessageAppExited, 5*time.Second)
+
+			select {
+			case event := <-waitFor(events, MessageAppStarted):
+				if !tt.restarted {
+					t.Fatalf("restarted: %+v", event.Data)
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.restarted {
+					t.Fatal("not restarted")
+				}
+			}
+		})
+	}
+}
+
+func TestManagedProcessStopIsNotACrash(t *testing.T) {
+	mp, events := newTestProcess(t, ProcessConfig{
+		Name:          "worker",
+		Command:       "sleep 30",
+		RestartPolicy: RestartPolicy{Policy: RestartAlways, Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+	mp.Start("test")
+	waitEvent(t, events, MessageAppStarted, 5*time.Second)
+
+	if err := mp.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, MessageAppExited, 5*time.Second)
+
+	select {
+	case event := <-waitFor(events, MessageAppCrash, MessageAppStarted):
+		t.Fatalf("unexpected %s after stop", event.Type)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestManagedProcessStartStopsRunning(t *testing.T) {
+	mp, events := newTestProcess(t, ProcessConfig{Name: "worker", Command: "sleep 30"})
+	mp.Start("build-1")
+	waitEvent(t, events, MessageAppStarted, 5*time.Second)
+	first, _ := mp.current()
+
+	mp.Start("build-2")
+	if !first.Exited() {
+		t.Fatal("previous process still running")
+	}
+	waitEvent(t, events, MessageAppStarted, 5*time.Second)
+	if current, _ := mp.current(); current == first || current.Exited() {
+		t.Fatal("new process not running")
+	}
+	select {
+	case <-waitFor(events, MessageAppCrash):
+		t.Fatal("stopping the previous process reported a crash")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestManagedProcessOutputLimited(t *testing.T) {
+	mp, events := newTestProcess(t, ProcessConfig{Name: "worker", Command: "seq 150"})
+	mp.Start("test")
+
+	var lines int
+	var dropped []int
+	deadline := time.After(5 * time.Second)
+	for exited := false; !exited; {
+		select {
+		case event := <-events:
+			switch event.Type {
+			case MessageAppOutput:
+				if data := event.Data.(AppOutputData); data.Dropped == 0 {
+					lines++
+				} else if data.Line != "... 50 lines skipped" {
+					t.Fatalf("summary = %+v", data)
+				} else {
+					dropped = append(dropped, data.Dropped)
+				}
+			case MessageAppExited:
+				exited = true
+			}
+		case <-deadline:
+			t.Fatal("no app-exited event")
+		}
+	}
+
+	// Skipped lines are still recorded
+	if lines != appOutputRate || len(dropped) != 1 || dropped[0] != 50 {
+		t.Fatalf("published %d lines and dropped %v", lines, dropped)
+	}
+	if tail := mp.logs.Tail(1); len(tail) != 1 || tail[0].Line != "150" {
+		t.Fatalf("last recorded line = %v", tail)
+	}
+}
+
+// waitFor forwards the first event of one of the given types
+func waitFor(events <-chan Event, types ...MessageType) <-chan Event {
+	found := make(chan Event, 1)
+	go func() {
+		for event := range events {
+			for _, msgType := range types {
+				if event.Type == msgType {
+					found <- event
+					return
+				}
+			}
+		}
+	}()
+	return found
+}
+
+func TestNewProcessManagerValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes []ProcessConfig
+		wantErr   string
+	}{
+		{"valid", []ProcessConfig{{Name: "worker", Command: "sleep 1"}, {Name: "css", Command: "sleep 1"}}, ""},
+		{"missing name", []ProcessConfig{{Command: "sleep 1"}}, "process name is required"},
+		{"missing command", []ProcessConfig{{Name: "worker"}}, `process "worker": command is required`},
+		{"duplicate name", []ProcessConfig{{Name: "worker", Command: "sleep 1"}, {Name: "worker", Command: "sleep 1"}}, `duplicate process name "worker"`},
+		{"app name taken", []ProcessConfig{{Name: appProcessName, Command: "sleep 1"}}, `duplicate process name "app"`},
+		{"invalid restart policy", []ProcessConfig{{Name: "worker", Command: "sleep 1", RestartPolicy: RestartPolicy{Policy: "sometimes"}}}, `process "worker": invalid restart_policy`},
+		{"invalid ready log", []ProcessConfig{{Name: "worker", Command: "sleep 1", Ready: ReadyCheck{Log: "("}}}, "invalid ready.log pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Processes = tt.processes
+			_, err := NewProcessManager(config, NewBuildTracker(t.TempDir()))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagedProcessWaitReady(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		command string
+		ready   ReadyCheck
+		wantErr string
+	}{
+		{"no check", "sleep 30", ReadyCheck{}, ""},
+		{"log", "echo booting; echo listening on 8080; sleep 30", ReadyCheck{Log: `listening on \d+`}, ""},
+		{"tcp", "sleep 30", ReadyCheck{TCP: listener.Addr().String()}, ""},
+		{"http", "sleep 30", ReadyCheck{HTTP: server.URL}, ""},
+		{"log then http", "echo listening; sleep 30", ReadyCheck{Log: "listening", HTTP: server.URL}, ""},
+		{"exits first", "exit 1", ReadyCheck{Log: "listening"}, "exited before becoming ready"},
+		{"timeout", "sleep 30", ReadyCheck{Log: "listening", Timeout: 300 * time.Millisecond}, "did not become ready within 300ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp, _ := newTestProcess(t, ProcessConfig{Name: "worker", Command: tt.command, Ready: tt.ready})
+			mp.Start("test")
+
+			err := mp.WaitReady()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
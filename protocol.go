@@ -0,0 +1,291 @@
+package godevwatch
+
+//go:generate go run ./cmd/godevwatch schema -o assets/protocol.schema.json
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// ProtocolVersion is the current version of the WebSocket message protocol
+	ProtocolVersion = 1
+
+	// MinProtocolVersion is the oldest protocol version clients may request
+	MinProtocolVersion = 1
+)
+
+// MessageType identifies the kind of a WebSocket message
+type MessageType string
+
+const (
+	MessageHello         MessageType = "hello"
+	MessageBuildStatus   MessageType = "build-status"
+	MessageBuildStarted  MessageType = "build-started"
+	MessageRuleStarted   MessageType = "rule-started"
+	MessageBuildOutput   MessageType = "build-output"
+	MessageBuildFinished MessageType = "build-finished"
+	MessageAppStarted    MessageType = "app-started"
+	MessageAppExited     MessageType = "app-exited"
+	MessageAppOutput     MessageType = "app-output"
+	MessageAppCrash      MessageType = "app-crash"
+	MessageWatcherStatus MessageType = "watcher-status"
+	MessageServerStatus  MessageType = "server-status"
+	MessageReload        MessageType = "reload"
+)
+
+// isOutput reports whether messages of this type carry build or process
+// output, which is dropped under load before lifecycle messages are
+func (t MessageType) isOutput() bool {
+	return t == MessageBuildOutput || t == MessageAppOutput
+}
+
+// Message is the envelope of every message sent to WebSocket clients
+type Message struct {
+	Version int         `json:"v"`
+	Type    MessageType `json:"type"`
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data"`
+}
+
+// HelloData is sent first on every connection with the negotiated protocol version
+type HelloData struct {
+	Protocol    int    `json:"protocol"`
+	MinProtocol int    `json:"min_protocol"`
+	MaxProtocol int    `json:"max_protocol"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BuildStatusData lists the builds that currently have a status file
+type BuildStatusData struct {
+	Builds []Build `json:"builds"`
+}
+
+// BuildStartedData is sent when a new build begins
+type BuildStartedData struct {
+	BuildID      string   `json:"build_id"`
+	Rules        []string `json:"rules"`
+	ChangedFiles []string `json:"changed_files,omitempty"`
+}
+
+// RuleStartedData is sent when a build rule starts running
+type RuleStartedData struct {
+	BuildID string `json:"build_id"`
+	Rule    string `json:"rule"`
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+}
+
+// BuildOutputData carries a line of output from a build rule. When lines are
+// dropped by rate limiting, a message with Dropped set reports how many.
+type BuildOutputData struct {
+	BuildID string `json:"build_id"`
+	Rule    string `json:"rule"`
+	Stream  string `json:"stream"`
+	Line    string `json:"line"`
+	Dropped int    `json:"dropped,omitempty"`
+}
+
+// BuildFinishedData is sent when a build succeeds, fails or is aborted
+type BuildFinishedData struct {
+	BuildID    string  `json:"build_id"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Rule       string  `json:"rule,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// AppStartedData is sent when the application or another managed process starts
+type AppStartedData struct {
+	Process string `json:"process"`
+	BuildID string `json:"build_id"`
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+}
+
+// AppExitedData is sent when the application or another managed process exits
+type AppExitedData struct {
+	Process  string `json:"process"`
+	BuildID  string `json:"build_id"`
+	PID      int    `json:"pid"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AppCrashData is sent when the application exits on its own with an error,
+// with its last lines of output and what happens next
+type AppCrashData struct {
+	Process    string   `json:"process"`
+	BuildID    string   `json:"build_id"`
+	PID        int      `json:"pid"`
+	ExitCode   int      `json:"exit_code"`
+	Signal     string   `json:"signal,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Output     []string `json:"output"`
+	Attempt    int      `json:"attempt"`
+	Restarting bool     `json:"restarting"`
+	RetryInMs  float64  `json:"retry_in_ms,omitempty"`
+	CrashLoop  bool     `json:"crash_loop"`
+}
+
+// AppOutputData carries a line of output from the application or another
+// managed process. Level is set for structured lines when pretty log
+// formatting is enabled. When lines are dropped by rate limiting, a message
+// with Dropped set reports how many.
+type AppOutputData struct {
+	Process string `json:"process"`
+	BuildID string `json:"build_id"`
+	PID     int    `json:"pid"`
+	Stream  string `json:"stream"`
+	Level   string `json:"level,omitempty"`
+	Line    string `json:"line"`
+	Dropped int    `json:"dropped,omitempty"`
+}
+
+// WatcherStatusData reports whether file watching is paused
+type WatcherStatusData struct {
+	Paused         bool `json:"paused"`
+	PendingChanges int  `json:"pending_changes"`
+}
+
+// ServerStatusData reports a backend becoming ready or going down
+type ServerStatusData struct {
+	Status   string          `json:"status"`
+	Backend  string          `json:"backend"`
+	Backends []BackendStatus `json:"backends"`
+}
+
+// ReloadData asks clients to reload the page
+type ReloadData struct {
+	Reason string `json:"reason"`
+}
+
+// messageData maps each message type to its payload type
+var messageData = map[MessageType]interface{}{
+	MessageHello:         HelloData{},
+	MessageBuildStatus:   BuildStatusData{},
+	MessageBuildStarted:  BuildStartedData{},
+	MessageRuleStarted:   RuleStartedData{},
+	MessageBuildOutput:   BuildOutputData{},
+	MessageBuildFinished: BuildFinishedData{},
+	MessageAppStarted:    AppStartedData{},
+	MessageAppExited:     AppExitedData{},
+	MessageAppOutput:     AppOutputData{},
+	MessageAppCrash:      AppCrashData{},
+	MessageWatcherStatus: WatcherStatusData{},
+	MessageServerStatus:  ServerStatusData{},
+	MessageReload:        ReloadData{},
+}
+
+// messageOrder lists message types in the order they appear in the schema
+var messageOrder = []MessageType{
+	MessageHello,
+	MessageBuildStatus,
+	MessageBuildStarted,
+	MessageRuleStarted,
+	MessageBuildOutput,
+	MessageBuildFinished,
+	MessageAppStarted,
+	MessageAppExited,
+	MessageAppOutput,
+	MessageAppCrash,
+	MessageWatcherStatus,
+	MessageServerStatus,
+	MessageReload,
+}
+
+// NewMessage wraps data in a message envelope for the current protocol version
+func NewMessage(msgType MessageType, data interface{}) Message {
+	return Message{
+		Version: ProtocolVersion,
+		Type:    msgType,
+		Time:    time.Now(),
+		Data:    data,
+	}
+}
+
+// encodeMessage builds the JSON message sent to WebSocket clients
+func encodeMessage(msgType MessageType, data interface{}) ([]byte, error) {
+	return json.Marshal(NewMessage(msgType, data))
+}
+
+// MessageSchema returns a JSON Schema describing every message of the protocol
+func MessageSchema() ([]byte, error) {
+	var variants []interface{}
+	for _, msgType := range messageOrder {
+		variants = append(variants, map[string]interface{}{
+			"type":     "object",
+			"required": []string{"v", "type", "time", "data"},
+			"properties": map[string]interface{}{
+				"v":    map[string]interface{}{"const": ProtocolVersion},
+				"type": map[string]interface{}{"const": string(msgType)},
+				"time": map[string]interface{}{"type": "string", "format": "date-time"},
+				"data": typeSchema(reflect.TypeOf(messageData[msgType])),
+			},
+		})
+	}
+
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "https://github.com/kyco/godevwatch/protocol.schema.json",
+		"title":   "godevwatch WebSocket message",
+		"oneOf":   variants,
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// typeSchema returns the JSON Schema for a Go type
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
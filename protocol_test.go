@@ -0,0 +1,104 @@
+package godevwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageTypesHavePayloads(t *testing.T) {
+	seen := make(map[MessageType]bool)
+	for _, msgType := range messageOrder {
+		if seen[msgType] {
+			t.Errorf("%s listed twice", msgType)
+		}
+		seen[msgType] = true
+		if _, ok := messageData[msgType]; !ok {
+			t.Errorf("%s has no payload type", msgType)
+		}
+	}
+	for msgType := range messageData {
+		if !seen[msgType] {
+			t.Errorf("%s missing from the schema order", msgType)
+		}
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	data, err := encodeMessage(MessageReload, ReloadData{Reason: "build-succeeded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Version int             `json:"v"`
+		Type    string          `json:"type"`
+		Time    time.Time       `json:"time"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != ProtocolVersion || decoded.Type != "reload" || decoded.Time.IsZero() {
+		t.Fatalf("envelope = %s", data)
+	}
+	if string(decoded.Data) != `{"reason":"build-succeeded"}` {
+		t.Fatalf("data = %s", decoded.Data)
+	}
+}
+
+func TestTypeSchema(t *testing.T) {
+	type payload struct {
+		Name     string `json:"name"`
+		Count    int    `json:"count,omitempty"`
+		Skipped  string `json:"-"`
+		Untagged bool
+		hidden   string            // Unexported fields are left out
+		Labels   map[string]string `json:"labels"`
+	}
+
+	tests := []struct {
+		name string
+		typ  interface{}
+		want string
+	}{
+		{"string", "", `{"type":"string"}`},
+		{"integer", int64(0), `{"type":"integer"}`},
+		{"number", 0.5, `{"type":"number"}`},
+		{"boolean", false, `{"type":"boolean"}`},
+		{"time", time.Time{}, `{"format":"date-time","type":"string"}`},
+		{"slice", []string{}, `{"items":{"type":"string"},"type":"array"}`},
+		{"pointer", new(int), `{"type":"integer"}`},
+		{"struct", payload{}, `{"additionalProperties":false,"properties":{"Untagged":{"type":"boolean"},"count":{"type":"integer"},` +
+			`"labels":{"additionalProperties":{"type":"string"},"type":"object"},"name":{"type":"string"}},"required":["name","Untagged","labels"],"type":"object"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(typeSchema(reflect.TypeOf(tt.typ)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("schema = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageSchemaUpToDate(t *testing.T) {
+	schema, err := MessageSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("assets/protocol.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(schema, '\n'), committed) {
+		t.Fatal("assets/protocol.schema.json is out of date, run go generate ./...")
+	}
+}
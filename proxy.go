@@ -5,13 +5,15 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
-	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -24,33 +26,183 @@ var clientReloadJS []byte
 //go:embed assets/server-down.html
 var serverDownHTML []byte
 
+//go:embed assets/requests.html
+var requestsHTML []byte
+
+//go:embed assets/dashboard.html
+var dashboardHTML []byte
+
+//go:embed assets/protocol.schema.json
+var protocolSchemaJSON []byte
+
+// maxBufferedBuildOutput is how many build output lines are sent to newly
+// connected clients. It must leave room in the hub's per-client send queue.
+const maxBufferedBuildOutput = 50
+
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin: isSameOrigin,
 }
 
 // ProxyServer represents the development proxy server
 type ProxyServer struct {
 	config       *Config
 	buildTracker *BuildTracker
-	wsClients    map[*websocket.Conn]bool
-	watcher      *fsnotify.Watcher
+	backends     []*Backend
+	static       []*StaticHandler
+	inspector    *RequestInspector
+	replayer     *Replayer
+	faults       *FaultInjector
+	hub          *Hub
+	dashboard    *Dashboard
+	fileWatcher  *FileWatcher
+	unsubscribe  func()
+	buildOutput  []BuildOutputData
+	outputMu     sync.Mutex
+	watcher      EventSource
 }
 
 // NewProxyServer creates a new proxy server
 func NewProxyServer(config *Config, buildTracker *BuildTracker) (*ProxyServer, error) {
-	watcher, err := fsnotify.NewWatcher()
+	if err := config.Watcher.Validate(); err != nil {
+		return nil, err
+	}
+	watcher, err := newEventSource(config.Watcher)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
-	return &ProxyServer{
+	ps := &ProxyServer{
 		config:       config,
 		buildTracker: buildTracker,
-		wsClients:    make(map[*websocket.Conn]bool),
+		hub:          NewHub(),
+		dashboard:    NewDashboard(),
 		watcher:      watcher,
-	}, nil
+	}
+
+	if err := ps.setupBackends(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	ps.faults, err = NewFaultInjector(config.Faults)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	if config.Inspector.Enabled {
+		ps.inspector = NewRequestInspector(config.Inspector)
+		ps.replayer = NewReplayer(ps)
+	}
+
+	for _, mount := range config.Static {
+		handler, err := NewStaticHandler(mount, ps)
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+		ps.static = append(ps.static, handler)
+	}
+
+	// Forward build and application events to clients, starting before the first build
+	events, unsubscribe := buildTracker.Events().Subscribe()
+	ps.unsubscribe = unsubscribe
+	go ps.forwardEvents(events)
+
+	return ps, nil
+}
+
+// setupBackends creates a backend for each configured route, falling back to BackendPort
+func (ps *ProxyServer) setupBackends() error {
+	routes := append([]Route{}, ps.config.Routes...)
+	if ps.config.BackendPort != 0 {
+		routes = append(routes, Route{Name: defaultBackendName, Port: ps.config.BackendPort})
+	}
+
+	for i, route := range routes {
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+
+		backend, err := NewBackend(route)
+		if err != nil {
+			return err
+		}
+
+		// Modify response to inject client script
+		if ps.config.InjectScript {
+			name := route.Name
+			backend.proxy.ModifyResponse = func(resp *http.Response) error {
+				contentType := resp.Header.Get("Content-Type")
+				if strings.Contains(contentType, "text/html") {
+					return ps.injectClientScript(resp, name)
+				}
+				return nil
+			}
+		}
+
+		ps.backends = append(ps.backends, backend)
+	}
+
+	if len(ps.backends) == 0 && len(ps.config.Static) == 0 {
+		return fmt.Errorf("no backends configured: set backend_port, routes or static")
+	}
+
+	return nil
+}
+
+// matchBackend returns the most specific backend matching the request, or nil
+func (ps *ProxyServer) matchBackend(r *http.Request) *Backend {
+	var best *Backend
+	for _, backend := range ps.backends {
+		if !backend.Matches(r) {
+			continue
+		}
+		if best == nil || backend.specificity() > best.specificity() {
+			best = backend
+		}
+	}
+	return best
+}
+
+// matchStatic returns the static mount that should serve the request instead of backend, or nil
+func (ps *ProxyServer) matchStatic(r *http.Request, backend *Backend) *StaticHandler {
+	var best *StaticHandler
+	for _, handler := range ps.static {
+		if !handler.Matches(r) {
+			continue
+		}
+		if best == nil || handler.specificity() > best.specificity() {
+			best = handler
+		}
+	}
+
+	if best == nil || (backend != nil && backend.specificity() >= best.specificity()) {
+		return nil
+	}
+	return best
+}
+
+// findBackend returns the backend with the given name, or the default backend when name is empty
+func (ps *ProxyServer) findBackend(name string) *Backend {
+	if name == "" {
+		name = defaultBackendName
+	}
+	for _, backend := range ps.backends {
+		if backend.Name() == name {
+			return backend
+		}
+	}
+	return nil
+}
+
+// backendStatuses returns the readiness of every backend
+func (ps *ProxyServer) backendStatuses() []BackendStatus {
+	statuses := make([]BackendStatus, 0, len(ps.backends))
+	for _, backend := range ps.backends {
+		statuses = append(statuses, backend.Status())
+	}
+	return statuses
 }
 
 // Start starts the proxy server
@@ -73,15 +225,90 @@ func (ps *ProxyServer) Start() error {
 	// Server status endpoint
 	mux.HandleFunc("/.godevwatch-server-status", ps.handleServerStatus)
 
+	// Per-backend status endpoint
+	mux.HandleFunc("/.godevwatch-backends", ps.handleBackends)
+
+	// Live reload client script
+	mux.HandleFunc(clientScriptPath, ps.handleClientScript)
+
+	// Dashboard
+	mux.Handle("/.godevwatch", http.RedirectHandler("/.godevwatch/", http.StatusMovedPermanently))
+	mux.HandleFunc("/.godevwatch/{$}", ps.handleDashboard)
+	mux.HandleFunc("/.godevwatch/state", ps.handleDashboardState)
+	mux.HandleFunc("/.godevwatch/actions/", sameOrigin(ps.handleDashboardAction))
+
+	// Application logs
+	mux.HandleFunc("/.godevwatch/logs", ps.handleLogs)
+
+	// WebSocket message schema
+	mux.HandleFunc("/.godevwatch/protocol.schema.json", ps.handleProtocolSchema)
+
+	// Fault injection control endpoint
+	mux.HandleFunc("/.godevwatch/faults", sameOrigin(ps.handleFaults))
+	mux.HandleFunc("/.godevwatch/faults/", sameOrigin(ps.handleFault))
+
+	// Request inspector viewer and API
+	if ps.inspector != nil {
+		mux.HandleFunc("/.godevwatch/requests", sameOrigin(ps.handleRequests))
+		mux.HandleFunc("/.godevwatch/requests/", sameOrigin(ps.handleRequest))
+		mux.HandleFunc("/.godevwatch/replays", sameOrigin(ps.handleReplays))
+	}
+
 	// Proxy all other requests
 	mux.HandleFunc("/", ps.handleProxy)
 
 	addr := fmt.Sprintf(":%d", ps.config.ProxyPort)
+
+	if ps.config.TLS.Enabled {
+		return ps.startTLS(addr, mux)
+	}
+
 	log.Printf("\033[32m✓ Proxy server running on http://localhost:%d\033[0m\n", ps.config.ProxyPort)
 
 	return http.ListenAndServe(addr, mux)
 }
 
+// startTLS serves the proxy over HTTPS, optionally redirecting plain HTTP requests
+func (ps *ProxyServer) startTLS(addr string, handler http.Handler) error {
+	tlsConfig, err := LoadOrCreateCertificates(ps.config.TLS.CertDir, ps.config.TLS.Hostnames)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificates: %w", err)
+	}
+
+	if ps.config.TLS.RedirectPort != 0 {
+		go ps.serveHTTPSRedirect()
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+
+	log.Printf("\033[32m✓ Proxy server running on https://localhost:%d\033[0m\n", ps.config.ProxyPort)
+
+	return server.ListenAndServeTLS("", "")
+}
+
+// serveHTTPSRedirect redirects plain HTTP requests to the HTTPS proxy
+func (ps *ProxyServer) serveHTTPSRedirect() {
+	addr := fmt.Sprintf(":%d", ps.config.TLS.RedirectPort)
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		target := fmt.Sprintf("https://%s%s", net.JoinHostPort(host, strconv.Itoa(ps.config.ProxyPort)), r.URL.RequestURI())
+		http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	})
+
+	log.Printf("\033[32m✓ Redirecting http://localhost:%d to HTTPS\033[0m\n", ps.config.TLS.RedirectPort)
+
+	if err := http.ListenAndServe(addr, redirect); err != nil {
+		log.Printf("HTTPS redirect server error: %v", err)
+	}
+}
+
 // handleWebSocket handles WebSocket connections
 func (ps *ProxyServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -90,28 +317,57 @@ func (ps *ProxyServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ps.wsClients[conn] = true
+	// Negotiate the protocol version, clients that do not ask get the current one
+	version := ProtocolVersion
+	if requested := r.URL.Query().Get("protocol"); requested != "" {
+		version, err = strconv.Atoi(requested)
+		if err != nil {
+			version = 0
+		}
+	}
+
+	hello := HelloData{
+		Protocol:    version,
+		MinProtocol: MinProtocolVersion,
+		MaxProtocol: ProtocolVersion,
+	}
+	if version < MinProtocolVersion || version > ProtocolVersion {
+		hello.Protocol = ProtocolVersion
+		hello.Error = fmt.Sprintf("unsupported protocol version %q", r.URL.Query().Get("protocol"))
+		conn.WriteJSON(NewMessage(MessageHello, hello))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, hello.Error))
+		conn.Close()
+		return
+	}
+
+	initial := make([][]byte, 0, 2)
+	if message, err := encodeMessage(MessageHello, hello); err == nil {
+		initial = append(initial, message)
+	}
 
 	// Send initial build status
 	builds, err := ps.buildTracker.GetBuilds()
 	if err == nil {
-		ps.sendToClient(conn, "build-status", builds)
+		if message, err := encodeMessage(MessageBuildStatus, BuildStatusData{Builds: builds}); err == nil {
+			initial = append(initial, message)
+		}
 	}
 
-	// Keep connection alive
-	go func() {
-		defer func() {
-			delete(ps.wsClients, conn)
-			conn.Close()
-		}()
+	// Show the crash overlay on pages opened after the app crashed
+	if crash := ps.dashboard.LastCrash(); crash != nil {
+		if message, err := encodeMessage(MessageAppCrash, crash); err == nil {
+			initial = append(initial, message)
+		}
+	}
 
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				break
-			}
+	// Replay the current build's output so pages opened mid-build see it
+	for _, line := range ps.bufferedBuildOutput() {
+		if message, err := encodeMessage(MessageBuildOutput, line); err == nil {
+			initial = append(initial, message)
 		}
-	}()
+	}
+
+	ps.hub.Serve(conn, initial...)
 }
 
 // handleBuildStatus returns the current build status
@@ -128,44 +384,294 @@ func (ps *ProxyServer) handleBuildStatus(w http.ResponseWriter, r *http.Request)
 
 // handleServerStatus checks if the backend server is running
 func (ps *ProxyServer) handleServerStatus(w http.ResponseWriter, r *http.Request) {
-	isRunning := ps.checkBackendServer()
+	backend := ps.findBackend(r.URL.Query().Get("backend"))
+	if backend == nil {
+		http.Error(w, "Unknown backend", http.StatusNotFound)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/plain")
-	if isRunning {
+	if backend.IsReady() {
 		w.Write([]byte("server-running"))
 	} else {
 		w.Write([]byte("server-down"))
 	}
 }
 
-// handleProxy proxies requests to the backend server
+// handleBackends returns the status of every backend
+func (ps *ProxyServer) handleBackends(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps.backendStatuses())
+}
+
+// handleClientScript serves the live reload client script
+func (ps *ProxyServer) handleClientScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(clientReloadJS)
+}
+
+// sameOrigin rejects state-changing requests from other origins, so a page
+// open in the browser cannot rebuild the app or inject faults
+func sameOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !isSameOrigin(r) {
+				http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// isSameOrigin reports whether a request comes from a page served by the
+// proxy itself, or from a client such as curl that sends no origin headers
+func isSameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+		// Older browsers only send Origin
+	default:
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// handleProtocolSchema serves the JSON Schema of the WebSocket messages
+func (ps *ProxyServer) handleProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(protocolSchemaJSON)
+}
+
+// handleRequests serves the request inspector viewer, or the recorded requests as JSON
+func (ps *ProxyServer) handleRequests(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		ps.inspector.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		if !wantsJSON(r) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write(requestsHTML)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ps.inspector.Records())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRequest returns a single recorded request as JSON, or marks it for replay
+func (ps *ProxyServer) handleRequest(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/.godevwatch/requests/")
+	idPart, action, _ := strings.Cut(rest, "/")
+
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	if action == "replay" {
+		ps.handleMarkReplay(w, r, id)
+		return
+	}
+	if action != "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	record, ok := ps.inspector.Get(id)
+	if !ok {
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// handleMarkReplay marks (POST) or unmarks (DELETE) a recorded request for replay
+func (ps *ProxyServer) handleMarkReplay(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodPost:
+		record, ok := ps.inspector.Get(id)
+		if !ok {
+			http.Error(w, "Request not found", http.StatusNotFound)
+			return
+		}
+		if err := ps.replayer.Mark(record); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("\033[36mMarked %s %s for replay after each build\033[0m\n", record.Method, record.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if !ps.replayer.Unmark(id) {
+			http.Error(w, "Request not marked", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReplays returns marked requests with their replay results, or replays them now (POST)
+func (ps *ProxyServer) handleReplays(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		ps.replayer.ReplayAll(false)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps.replayer.Marked())
+}
+
+// handleFaults lists fault rules, or adds or replaces one (POST)
+func (ps *ProxyServer) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var rule FaultRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid fault rule: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := ps.faults.Set(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("\033[35mFault rule %q updated (enabled: %t)\033[0m\n", rule.Name, rule.Enabled)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps.faults.Rules())
+}
+
+// handleFault enables, disables or removes a single fault rule
+func (ps *ProxyServer) handleFault(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/.godevwatch/faults/")
+	name, action, _ := strings.Cut(rest, "/")
+
+	var found bool
+	switch {
+	case r.Method == http.MethodDelete && action == "":
+		found = ps.faults.Remove(name)
+	case r.Method == http.MethodPost && (action == "enable" || action == "disable"):
+		found = ps.faults.SetEnabled(name, action == "enable")
+		if found {
+			log.Printf("\033[35mFault rule %q %sd\033[0m\n", name, action)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !found {
+		http.Error(w, "Fault rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps.faults.Rules())
+}
+
+// handleProxy proxies requests to the matching backend server
 func (ps *ProxyServer) handleProxy(w http.ResponseWriter, r *http.Request) {
-	// Check if backend server is running
-	if !ps.checkBackendServer() {
-		w.Header().Set("Content-Type", "text/html")
-		w.Write(serverDownHTML)
+	// Record the request in the inspector
+	var capture *CaptureWriter
+	if ps.inspector != nil {
+		capture = ps.inspector.Capture(w, r)
+		defer capture.Finish()
+		w = capture
+	}
+
+	backend := ps.matchBackend(r)
+	static := ps.matchStatic(r, backend)
+
+	name := ""
+	switch {
+	case static != nil:
+		name = static.Name()
+	case backend != nil:
+		name = backend.Name()
+	}
+	if capture != nil {
+		capture.SetBackend(name)
+	}
+
+	// Inject configured latency and failures
+	if rule, ok := ps.faults.Match(r, name); ok {
+		var proceed bool
+		if w, proceed = ps.faults.Apply(rule, w, r); !proceed {
+			return
+		}
+	}
+
+	if static != nil {
+		static.ServeHTTP(w, r)
 		return
 	}
 
-	// Create reverse proxy
-	target, _ := url.Parse(fmt.Sprintf("http://localhost:%d", ps.config.BackendPort))
-	proxy := httputil.NewSingleHostReverseProxy(target)
+	if backend == nil {
+		http.Error(w, "No route matches this request", http.StatusBadGateway)
+		return
+	}
 
-	// Modify response to inject client script
-	if ps.config.InjectScript {
-		proxy.ModifyResponse = func(resp *http.Response) error {
-			contentType := resp.Header.Get("Content-Type")
-			if strings.Contains(contentType, "text/html") {
-				return ps.injectClientScript(resp)
-			}
-			return nil
+	// Check if backend server is running
+	if !backend.IsReady() {
+		// API calls, event streams and WebSockets get a retryable error instead of the waiting page
+		if !isPageRequest(r) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Backend server is not running", http.StatusServiceUnavailable)
+			return
 		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(bytes.Replace(serverDownHTML, []byte(`data-backend=""`), []byte(fmt.Sprintf(`data-backend="%s"`, html.EscapeString(backend.Name()))), 1))
+		return
+	}
+
+	// Let the backend know the original request was made over HTTPS
+	if r.TLS != nil {
+		r.Header.Set("X-Forwarded-Proto", "https")
 	}
 
-	proxy.ServeHTTP(w, r)
+	backend.ServeHTTP(w, r)
+}
+
+// isPageRequest checks if a request is a browser page load rather than an API call, stream or WebSocket
+func isPageRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	return r.Header.Get("HX-Request") == "" && r.Header.Get("X-Requested-With") == ""
 }
 
 // injectClientScript injects the client reload script into HTML responses
-func (ps *ProxyServer) injectClientScript(resp *http.Response) error {
+func (ps *ProxyServer) injectClientScript(resp *http.Response, backendName string) error {
 	// Read the response body
 	body := make([]byte, 0)
 	buf := make([]byte, 4096)
@@ -180,15 +686,10 @@ func (ps *ProxyServer) injectClientScript(resp *http.Response) error {
 	}
 	resp.Body.Close()
 
-	// Inject script before </body>
-	html := string(body)
-	script := fmt.Sprintf("<script>%s</script>", clientReloadJS)
-	if strings.Contains(html, "</body>") {
-		html = strings.Replace(html, "</body>", script+"</body>", 1)
-	}
+	page := ps.injectScriptTag(string(body), resp.Header, resp.Request.Host, backendName)
 
 	// Update response body and headers
-	newBody := []byte(html)
+	newBody := []byte(page)
 	resp.Body = io.NopCloser(bytes.NewReader(newBody))
 	resp.ContentLength = int64(len(newBody))
 	resp.Header.Set("Content-Length", fmt.Sprintf("%d", len(newBody)))
@@ -196,11 +697,20 @@ func (ps *ProxyServer) injectClientScript(resp *http.Response) error {
 	return nil
 }
 
-// checkBackendServer checks if the backend server is running
-func (ps *ProxyServer) checkBackendServer() bool {
-	cmd := exec.Command("lsof", "-i", fmt.Sprintf(":%d", ps.config.BackendPort), "-sTCP:LISTEN", "-t")
-	output, err := cmd.Output()
-	return err == nil && len(strings.TrimSpace(string(output))) > 0
+// injectScriptTag inserts the client script tag before </body>, allowing it
+// through any content security policy in header
+func (ps *ProxyServer) injectScriptTag(page string, header http.Header, host, backendName string) string {
+	if !strings.Contains(page, "</body>") {
+		return page
+	}
+
+	nonce := applyCSP(header, host, page)
+	attrs := fmt.Sprintf(`src="%s" data-backend="%s"`, clientScriptPath, html.EscapeString(backendName))
+	if nonce != "" {
+		attrs += fmt.Sprintf(` nonce="%s"`, nonce)
+	}
+
+	return strings.Replace(page, "</body>", "<script "+attrs+"></script></body>", 1)
 }
 
 // watchBuildStatus watches for changes in the build status directory
@@ -212,14 +722,14 @@ func (ps *ProxyServer) watchBuildStatus() {
 
 	for {
 		select {
-		case event, ok := <-ps.watcher.Events:
+		case event, ok := <-ps.watcher.Events():
 			if !ok {
 				return
 			}
 			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create || event.Op&fsnotify.Remove == fsnotify.Remove {
 				ps.broadcastBuildStatus()
 			}
-		case err, ok := <-ps.watcher.Errors:
+		case err, ok := <-ps.watcher.Errors():
 			if !ok {
 				return
 			}
@@ -228,21 +738,68 @@ func (ps *ProxyServer) watchBuildStatus() {
 	}
 }
 
-// pollServerStatus polls the backend server status
+// forwardEvents broadcasts events published by the watcher and process manager
+func (ps *ProxyServer) forwardEvents(events <-chan Event) {
+	for event := range events {
+		ps.dashboard.Handle(event)
+
+		switch event.Type {
+		case MessageBuildStarted:
+			ps.outputMu.Lock()
+			ps.buildOutput = nil
+			ps.outputMu.Unlock()
+		case MessageReload:
+			// Published once the rebuilt app accepts requests
+			if reload, ok := event.Data.(ReloadData); ok && reload.Reason == "build-succeeded" && ps.replayer != nil {
+				ps.replayer.OnBuildSucceeded()
+			}
+		case MessageBuildOutput:
+			if line, ok := event.Data.(BuildOutputData); ok {
+				ps.outputMu.Lock()
+				ps.buildOutput = append(ps.buildOutput, line)
+				if len(ps.buildOutput) > maxBufferedBuildOutput {
+					ps.buildOutput = ps.buildOutput[len(ps.buildOutput)-maxBufferedBuildOutput:]
+				}
+				ps.outputMu.Unlock()
+			}
+		}
+		ps.broadcastToAll(event.Type, event.Data)
+	}
+}
+
+// bufferedBuildOutput returns the most recent output lines of the current build
+func (ps *ProxyServer) bufferedBuildOutput() []BuildOutputData {
+	ps.outputMu.Lock()
+	defer ps.outputMu.Unlock()
+	return append([]BuildOutputData{}, ps.buildOutput...)
+}
+
+// pollServerStatus polls the status of every backend and broadcasts changes
 func (ps *ProxyServer) pollServerStatus() {
-	lastStatus := false
+	lastStatus := make(map[string]string)
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		isRunning := ps.checkBackendServer()
-		if isRunning != lastStatus {
-			lastStatus = isRunning
-			status := "down"
-			if isRunning {
-				status = "running"
+		statuses := ps.backendStatuses()
+		for i, status := range statuses {
+			previous, seen := lastStatus[status.Name]
+			lastStatus[status.Name] = status.Status
+			if (!seen && status.Status == "down") || previous == status.Status {
+				continue
 			}
-			ps.broadcastToAll("server-status", map[string]string{"status": status})
+
+			// Drop proxied WebSockets and event streams still attached to the old process
+			if status.Status == "down" {
+				if n := ps.backends[i].CloseConnections(); n > 0 {
+					log.Printf("\033[33mClosed %d streaming connection(s) to %s\033[0m\n", n, status.Name)
+				}
+			}
+			ps.broadcastToAll(MessageServerStatus, ServerStatusData{
+				Status:   status.Status,
+				Backend:  status.Name,
+				Backends: statuses,
+			})
 		}
 	}
 }
@@ -253,45 +810,28 @@ func (ps *ProxyServer) broadcastBuildStatus() {
 	if err != nil {
 		return
 	}
-	ps.broadcastToAll("build-status", map[string]interface{}{"builds": builds})
+	ps.broadcastToAll(MessageBuildStatus, BuildStatusData{Builds: builds})
 }
 
 // broadcastToAll sends a message to all connected WebSocket clients
-func (ps *ProxyServer) broadcastToAll(msgType string, data interface{}) {
-	for client := range ps.wsClients {
-		ps.sendToClient(client, msgType, data)
-	}
-}
-
-// sendToClient sends a message to a specific WebSocket client
-func (ps *ProxyServer) sendToClient(client *websocket.Conn, msgType string, data interface{}) {
-	message := map[string]interface{}{
-		"type": msgType,
-	}
-
-	// Merge data into message
-	switch v := data.(type) {
-	case map[string]interface{}:
-		for k, val := range v {
-			message[k] = val
-		}
-	case map[string]string:
-		for k, val := range v {
-			message[k] = val
-		}
-	default:
-		message["data"] = data
+func (ps *ProxyServer) broadcastToAll(msgType MessageType, data interface{}) {
+	message, err := encodeMessage(msgType, data)
+	if err != nil {
+		log.Printf("Failed to encode message: %v", err)
+		return
 	}
-
-	if err := client.WriteJSON(message); err != nil {
-		log.Printf("Failed to send message to client: %v", err)
+	if msgType.isOutput() {
+		ps.hub.BroadcastOutput(message)
+		return
 	}
+	ps.hub.Broadcast(message)
 }
 
 // Close closes the proxy server
 func (ps *ProxyServer) Close() error {
-	for client := range ps.wsClients {
-		client.Close()
+	if ps.unsubscribe != nil {
+		ps.unsubscribe()
 	}
+	ps.hub.Stop()
 	return ps.watcher.Close()
 }
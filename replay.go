@@ -0,0 +1,319 @@
+package godevwatch
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// replayReadyTimeout is how long to wait for the backend after a build before giving up
+	replayReadyTimeout = 30 * time.Second
+
+	// maxDiffLines caps the number of lines compared when diffing response bodies
+	maxDiffLines = 500
+)
+
+// ReplayResult is the outcome of sending a request to the backend
+type ReplayResult struct {
+	Time       time.Time `json:"time"`
+	Status     int       `json:"status"`
+	Body       string    `json:"body,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// MarkedRequest is a captured request that is replayed after every successful build
+type MarkedRequest struct {
+	Request  RequestRecord `json:"request"`
+	Previous *ReplayResult `json:"previous,omitempty"`
+	Latest   *ReplayResult `json:"latest,omitempty"`
+	Changed  bool          `json:"changed"`
+	Diff     string        `json:"diff,omitempty"`
+}
+
+// Replayer replays marked requests against the rebuilt backend
+type Replayer struct {
+	proxy   *ProxyServer
+	marked  []*MarkedRequest
+	running bool
+	mu      sync.Mutex
+}
+
+// NewReplayer creates a new replayer for the proxy's backends
+func NewReplayer(proxy *ProxyServer) *Replayer {
+	return &Replayer{
+		proxy: proxy,
+	}
+}
+
+// Mark adds a recorded request to the replay set. The captured response, if
+// any, becomes the baseline the first replay is compared against.
+func (rp *Replayer) Mark(record RequestRecord) error {
+	if record.RequestSize > 0 && record.requestTruncated {
+		return fmt.Errorf("request body was truncated, increase inspector.max_body_size to replay it")
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for _, m := range rp.marked {
+		if m.Request.ID == record.ID {
+			return nil
+		}
+	}
+
+	// Without a captured response body the first replay becomes the baseline
+	marked := &MarkedRequest{Request: record}
+	if rp.proxy.config.Inspector.CaptureBodies {
+		marked.Latest = &ReplayResult{
+			Time:       record.Time,
+			Status:     record.Status,
+			Body:       string(record.rawResponseBody),
+			DurationMs: record.DurationMs,
+		}
+	}
+	rp.marked = append(rp.marked, marked)
+
+	return nil
+}
+
+// Unmark removes a request from the replay set
+func (rp *Replayer) Unmark(id int64) bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for i, m := range rp.marked {
+		if m.Request.ID == id {
+			rp.marked = append(rp.marked[:i], rp.marked[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Marked returns a snapshot of all marked requests and their latest results
+func (rp *Replayer) Marked() []MarkedRequest {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	marked := make([]MarkedRequest, 0, len(rp.marked))
+	for _, m := range rp.marked {
+		marked = append(marked, *m)
+	}
+	return marked
+}
+
+// OnBuildSucceeded replays all marked requests once their backends are ready again
+func (rp *Replayer) OnBuildSucceeded() {
+	rp.mu.Lock()
+	if len(rp.marked) == 0 || rp.running {
+		rp.mu.Unlock()
+		return
+	}
+	rp.running = true
+	rp.mu.Unlock()
+
+	go func() {
+		defer func() {
+			rp.mu.Lock()
+			rp.running = false
+			rp.mu.Unlock()
+		}()
+		rp.ReplayAll(true)
+	}()
+}
+
+// ReplayAll replays every marked request, optionally waiting for the backend to come up first
+func (rp *Replayer) ReplayAll(waitForBackend bool) {
+	rp.mu.Lock()
+	marked := append([]*MarkedRequest{}, rp.marked...)
+	rp.mu.Unlock()
+
+	if len(marked) == 0 {
+		return
+	}
+
+	log.Printf("\033[36mReplaying %d marked request(s)...\033[0m\n", len(marked))
+
+	for _, m := range marked {
+		req, err := m.Request.newReplayRequest()
+		if err != nil {
+			rp.record(m, &ReplayResult{Time: time.Now(), Error: err.Error()})
+			continue
+		}
+
+		backend := rp.proxy.matchBackend(req)
+		if backend == nil {
+			rp.record(m, &ReplayResult{Time: time.Now(), Error: "no backend matches this request"})
+			continue
+		}
+
+		if waitForBackend && !waitForReady(backend, replayReadyTimeout) {
+			rp.record(m, &ReplayResult{Time: time.Now(), Error: "backend did not become ready"})
+			continue
+		}
+
+		rp.record(m, replay(backend, req))
+	}
+}
+
+// record stores a replay result, diffs it against the previous one and logs the outcome
+func (rp *Replayer) record(m *MarkedRequest, result *ReplayResult) {
+	rp.mu.Lock()
+	m.Previous = m.Latest
+	m.Latest = result
+	m.Changed, m.Diff = diffResults(m.Previous, m.Latest)
+	changed, diff := m.Changed, m.Diff
+	rp.mu.Unlock()
+
+	label := fmt.Sprintf("%s %s", m.Request.Method, m.Request.Path)
+	switch {
+	case result.Error != "":
+		log.Printf("\033[31mReplay %s failed: %s\033[0m\n", label, result.Error)
+	case !changed:
+		log.Printf("\033[32mReplay %s: %d (unchanged)\033[0m\n", label, result.Status)
+	default:
+		log.Printf("\033[33mReplay %s: changed\033[0m\n", label)
+		for _, line := range strings.Split(diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				log.Printf("\033[31m  %s\033[0m\n", line)
+			case strings.HasPrefix(line, "+"):
+				log.Printf("\033[32m  %s\033[0m\n", line)
+			default:
+				log.Printf("  %s\n", line)
+			}
+		}
+	}
+}
+
+// newReplayRequest rebuilds an HTTP request from a recorded one
+func (rr RequestRecord) newReplayRequest() (*http.Request, error) {
+	// Use the original query, the recorded one may be redacted
+	target := rr.Path
+	if rr.rawQuery != "" {
+		target += "?" + rr.rawQuery
+	}
+
+	req, err := http.NewRequest(rr.Method, "http://"+rr.Host+target, bytes.NewReader(rr.rawRequestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build replay request: %w", err)
+	}
+
+	if rr.rawRequestHeaders != nil {
+		req.Header = rr.rawRequestHeaders.Clone()
+	}
+	req.Host = rr.Host
+	req.Header.Set("X-Godevwatch-Replay", "1")
+
+	return req, nil
+}
+
+// replay sends a request through the backend's reverse proxy and records the response
+func replay(backend *Backend, req *http.Request) *ReplayResult {
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	backend.ServeHTTP(rec, req)
+
+	result := &ReplayResult{
+		Time:       start,
+		Status:     rec.Code,
+		Body:       rec.Body.String(),
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if rec.Code == http.StatusBadGateway && rec.Body.Len() == 0 {
+		result.Error = "backend unreachable"
+	}
+
+	return result
+}
+
+// waitForReady polls a backend until it is ready or the timeout expires
+func waitForReady(backend *Backend, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if backend.IsReady() {
+			return true
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return false
+}
+
+// diffResults compares two replay results and returns whether they differ and a line diff
+func diffResults(previous, latest *ReplayResult) (bool, string) {
+	if previous == nil || latest == nil {
+		return false, ""
+	}
+
+	var out []string
+	if previous.Error != latest.Error {
+		out = append(out, "-error: "+previous.Error, "+error: "+latest.Error)
+	}
+	if previous.Status != latest.Status {
+		out = append(out, fmt.Sprintf("-status: %d", previous.Status), fmt.Sprintf("+status: %d", latest.Status))
+	}
+	if previous.Body != latest.Body {
+		out = append(out, diffLines(previous.Body, latest.Body)...)
+	}
+
+	return len(out) > 0, strings.Join(out, "\n")
+}
+
+// diffLines returns a minimal line diff between a and b, prefixing removed
+// lines with "-" and added lines with "+"
+func diffLines(a, b string) []string {
+	left := strings.Split(a, "\n")
+	right := strings.Split(b, "\n")
+	if len(left) > maxDiffLines {
+		left = left[:maxDiffLines]
+	}
+	if len(right) > maxDiffLines {
+		right = right[:maxDiffLines]
+	}
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(left)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(right)+1)
+	}
+	for i := len(left) - 1; i >= 0; i-- {
+		for j := len(right) - 1; j >= 0; j-- {
+			if left[i] == right[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(left) && j < len(right) {
+		switch {
+		case left[i] == right[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+left[i])
+			i++
+		default:
+			out = append(out, "+"+right[j])
+			j++
+		}
+	}
+	for ; i < len(left); i++ {
+		out = append(out, "-"+left[i])
+	}
+	for ; j < len(right); j++ {
+		out = append(out, "+"+right[j])
+	}
+
+	return out
+}
@@ -0,0 +1,144 @@
+package godevwatch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"identical", "a\nb", "a\nb", nil},
+		{"line changed", "a\nb\nc", "a\nB\nc", []string{"-b", "+B"}},
+		{"line added", "a\nc", "a\nb\nc", []string{"+b"}},
+		{"line removed", "a\nb\nc", "a\nc", []string{"-b"}},
+		{"trailing lines", "a", "a\nb\nc", []string{"+b", "+c"}},
+		{"empty to text", "", "a", []string{"-", "+a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("diffLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	ok := &ReplayResult{Status: 200, Body: `{"count":1}`}
+
+	tests := []struct {
+		name     string
+		previous *ReplayResult
+		latest   *ReplayResult
+		changed  bool
+		diff     string
+	}{
+		{"first result", nil, ok, false, ""},
+		{"unchanged", ok, &ReplayResult{Status: 200, Body: `{"count":1}`}, false, ""},
+		{"status", ok, &ReplayResult{Status: 500, Body: `{"count":1}`}, true, "-status: 200\n+status: 500"},
+		{"body", ok, &ReplayResult{Status: 200, Body: `{"count":2}`}, true, "-{\"count\":1}\n+{\"count\":2}"},
+		{"error", ok, &ReplayResult{Status: 502, Error: "backend unreachable"}, true,
+			"-error: \n+error: backend unreachable\n-status: 200\n+status: 502\n-{\"count\":1}\n+"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, diff := diffResults(tt.previous, tt.latest)
+			if changed != tt.changed || diff != tt.diff {
+				t.Fatalf("diffResults = %v %q, want %v %q", changed, diff, tt.changed, tt.diff)
+			}
+		})
+	}
+}
+
+// captureRecord records a request through an inspector, as the proxy does
+func captureRecord(t *testing.T, config InspectorConfig, r *http.Request, response string) RequestRecord {
+	t.Helper()
+
+	cw := NewRequestInspector(config).Capture(httptest.NewRecorder(), r)
+	io.ReadAll(r.Body)
+	io.WriteString(cw, response)
+	return cw.Finish()
+}
+
+func TestReplayer(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	var lastRequest atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastRequest.Store(fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), body, r.Header.Get("X-Godevwatch-Replay")))
+		fmt.Fprintf(w, "version %d", version.Load())
+	}))
+	defer upstream.Close()
+
+	backend, err := NewBackend(Route{Name: "api", URL: upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Inspector: InspectorConfig{CaptureBodies: true, Redact: []string{"token"}}}
+	rp := NewReplayer(&ProxyServer{config: config, backends: []*Backend{backend}})
+
+	r := httptest.NewRequest(http.MethodPost, "/items?token=secret", strings.NewReader("name=a"))
+	record := captureRecord(t, config.Inspector, r, "version 1")
+	record.ID = 7
+	if err := rp.Mark(record); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		version int32
+		changed bool
+		diff    string
+	}{
+		{"same as captured", 1, false, ""},
+		{"changed after rebuild", 2, true, "-version 1\n+version 2"},
+		{"unchanged since last replay", 2, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version.Store(tt.version)
+			rp.ReplayAll(false)
+
+			marked := rp.Marked()
+			if len(marked) != 1 {
+				t.Fatalf("marked = %v", marked)
+			}
+			if marked[0].Changed != tt.changed || marked[0].Diff != tt.diff {
+				t.Fatalf("changed = %v %q, want %v %q", marked[0].Changed, marked[0].Diff, tt.changed, tt.diff)
+			}
+		})
+	}
+
+	// The unredacted request is replayed, marked as a replay
+	if got := lastRequest.Load(); got != "POST /items?token=secret name=a 1" {
+		t.Fatalf("upstream received %q", got)
+	}
+
+	if !rp.Unmark(7) || rp.Unmark(7) || len(rp.Marked()) != 0 {
+		t.Fatal("Unmark did not remove the request once")
+	}
+}
+
+func TestReplayerMarkTruncated(t *testing.T) {
+	config := &Config{Inspector: InspectorConfig{MaxBodySize: 4}}
+	rp := NewReplayer(&ProxyServer{config: config})
+
+	r := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("name=a"))
+	record := captureRecord(t, config.Inspector, r, "ok")
+	if err := rp.Mark(record); err == nil || !strings.Contains(err.Error(), "max_body_size") {
+		t.Fatalf("err = %v", err)
+	}
+}
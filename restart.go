@@ -0,0 +1,82 @@
+package godevwatch
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// RestartNever leaves the app stopped after it exits
+	RestartNever = "never"
+
+	// RestartOnFailure restarts the app when it exits with an error or signal
+	RestartOnFailure = "on-failure"
+
+	// RestartAlways restarts the app whenever it exits on its own
+	RestartAlways = "always"
+
+	// defaultMaxRetries is how many restarts in a row are allowed before a crash loop is reported
+	defaultMaxRetries = 5
+
+	// crashResetAfter is how long the app must stay up for its retry count to reset
+	crashResetAfter = 10 * time.Second
+
+	// crashOutputLines is how many lines of output are included with a crash
+	crashOutputLines = 30
+)
+
+// UnmarshalYAML accepts either a policy name or a full restart policy
+func (rp *RestartPolicy) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&rp.Policy)
+	}
+
+	type plain RestartPolicy
+	return value.Decode((*plain)(rp))
+}
+
+// Validate checks the policy name and fills in defaults. A negative
+// max_retries restarts without limit.
+func (rp *RestartPolicy) Validate() error {
+	switch rp.Policy {
+	case "":
+		rp.Policy = RestartNever
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("invalid restart_policy %q, expected %q, %q or %q", rp.Policy, RestartNever, RestartOnFailure, RestartAlways)
+	}
+
+	if rp.MaxRetries == 0 {
+		rp.MaxRetries = defaultMaxRetries
+	}
+	if rp.Backoff <= 0 {
+		rp.Backoff = 500 * time.Millisecond
+	}
+	if rp.MaxBackoff < rp.Backoff {
+		rp.MaxBackoff = max(rp.Backoff, 30*time.Second)
+	}
+	return nil
+}
+
+// ShouldRestart reports whether an unexpected exit should be followed by a restart
+func (rp RestartPolicy) ShouldRestart(crashed bool) bool {
+	switch rp.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return crashed
+	default:
+		return false
+	}
+}
+
+// Delay returns the exponential backoff before the given restart attempt, starting at 1
+func (rp RestartPolicy) Delay(attempt int) time.Duration {
+	delay := rp.Backoff
+	for i := 1; i < attempt && delay < rp.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, rp.MaxBackoff)
+}
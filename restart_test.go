@@ -0,0 +1,112 @@
+package godevwatch
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRestartPolicyUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want RestartPolicy
+	}{
+		{"policy name", `on-failure`, RestartPolicy{Policy: RestartOnFailure}},
+		{"full policy", "policy: always\nmax_retries: 3\nbackoff: 1s\nmax_backoff: 10s",
+			RestartPolicy{Policy: RestartAlways, MaxRetries: 3, Backoff: time.Second, MaxBackoff: 10 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rp RestartPolicy
+			if err := yaml.Unmarshal([]byte(tt.yaml), &rp); err != nil {
+				t.Fatal(err)
+			}
+			if rp != tt.want {
+				t.Fatalf("policy = %+v, want %+v", rp, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RestartPolicy
+		want    RestartPolicy
+		wantErr string
+	}{
+		{"defaults", RestartPolicy{},
+			RestartPolicy{Policy: RestartNever, MaxRetries: defaultMaxRetries, Backoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second}, ""},
+		{"max backoff raised to backoff", RestartPolicy{Policy: RestartAlways, Backoff: time.Minute, MaxBackoff: time.Second},
+			RestartPolicy{Policy: RestartAlways, MaxRetries: defaultMaxRetries, Backoff: time.Minute, MaxBackoff: time.Minute}, ""},
+		{"explicit values kept", RestartPolicy{Policy: RestartOnFailure, MaxRetries: 2, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			RestartPolicy{Policy: RestartOnFailure, MaxRetries: 2, Backoff: time.Second, MaxBackoff: 5 * time.Second}, ""},
+		{"no retry limit", RestartPolicy{Policy: RestartAlways, MaxRetries: -1},
+			RestartPolicy{Policy: RestartAlways, MaxRetries: -1, Backoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second}, ""},
+		{"unknown policy", RestartPolicy{Policy: "sometimes"}, RestartPolicy{}, `invalid restart_policy "sometimes"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.policy != tt.want {
+				t.Fatalf("policy = %+v, want %+v", tt.policy, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy  string
+		crashed bool
+		want    bool
+	}{
+		{RestartNever, true, false},
+		{RestartNever, false, false},
+		{RestartOnFailure, true, true},
+		{RestartOnFailure, false, false},
+		{RestartAlways, true, true},
+		{RestartAlways, false, true},
+	}
+
+	for _, tt := range tests {
+		if got := (RestartPolicy{Policy: tt.policy}).ShouldRestart(tt.crashed); got != tt.want {
+			t.Errorf("%s ShouldRestart(%v) = %v, want %v", tt.policy, tt.crashed, got, tt.want)
+		}
+	}
+}
+
+func TestRestartPolicyDelay(t *testing.T) {
+	rp := RestartPolicy{Backoff: 500 * time.Millisecond, MaxBackoff: 3 * time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 500 * time.Millisecond},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{4, 3 * time.Second},
+		{100, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := rp.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package godevwatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// StaticHandler serves files from a local directory under a path prefix
+type StaticHandler struct {
+	mount  StaticMount
+	prefix string
+	proxy  *ProxyServer
+}
+
+// NewStaticHandler creates a handler for a static mount
+func NewStaticHandler(mount StaticMount, proxy *ProxyServer) (*StaticHandler, error) {
+	info, err := os.Stat(mount.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid static directory %q: %w", mount.Dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid static directory %q: not a directory", mount.Dir)
+	}
+
+	prefix := strings.TrimSuffix(ensureLeadingSlash(mount.PathPrefix), "/")
+
+	return &StaticHandler{
+		mount:  mount,
+		prefix: prefix,
+		proxy:  proxy,
+	}, nil
+}
+
+// Name returns the label used for this mount in logs and the client script
+func (sh *StaticHandler) Name() string {
+	return "static:" + ensureLeadingSlash(sh.prefix)
+}
+
+// Matches checks if a request path falls under this mount
+func (sh *StaticHandler) Matches(r *http.Request) bool {
+	return sh.prefix == "" || r.URL.Path == sh.prefix || strings.HasPrefix(r.URL.Path, sh.prefix+"/")
+}
+
+// specificity ranks static mounts against backend routes by prefix length
+func (sh *StaticHandler) specificity() int {
+	return len(sh.prefix) + 1
+}
+
+// ServeHTTP serves the requested file, falling back to index.html for SPAs
+func (sh *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Clean the path so it cannot escape the mounted directory
+	rel := path.Clean("/" + strings.TrimPrefix(r.URL.Path, sh.prefix))
+	file := filepath.Join(sh.mount.Dir, filepath.FromSlash(rel))
+
+	info, err := os.Stat(file)
+	if err == nil && info.IsDir() {
+		file = filepath.Join(file, "index.html")
+		info, err = os.Stat(file)
+	}
+
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || !sh.mount.SPA || !acceptsHTML(r) {
+			http.NotFound(w, r)
+			return
+		}
+
+		// History API fallback
+		file = filepath.Join(sh.mount.Dir, "index.html")
+		info, err = os.Stat(file)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	if sh.proxy.config.InjectScript && isHTMLFile(file) {
+		page := sh.proxy.injectScriptTag(string(content), w.Header(), r.Host, sh.Name())
+		content = []byte(page)
+	}
+
+	// Always revalidate in development, the ETag keeps unchanged files cheap
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), len(content)))
+
+	http.ServeContent(w, r, info.Name(), info.ModTime(), bytes.NewReader(content))
+}
+
+// acceptsHTML checks if a request is a page navigation rather than an asset fetch
+func acceptsHTML(r *http.Request) bool {
+	if path.Ext(r.URL.Path) != "" {
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	return accept == "" || strings.Contains(accept, "text/html") || strings.Contains(accept, "*/*")
+}
+
+// isHTMLFile checks if a file is an HTML document
+func isHTMLFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".html" || ext == ".htm"
+}
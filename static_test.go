@@ -0,0 +1,121 @@
+package godevwatch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaticHandler(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dist")
+	writeFile(t, dir, "index.html", "<html>home</html>")
+	writeFile(t, dir, "app.js", "console.log(1)")
+	writeFile(t, dir, "docs/index.html", "<html>docs</html>")
+	writeFile(t, root, "secret.txt", "secret")
+
+	proxy := &ProxyServer{config: &Config{}}
+
+	tests := []struct {
+		name        string
+		spa         bool
+		method      string
+		path        string
+		accept      string
+		status      int
+		body        string
+		contentType string
+	}{
+		{"file", false, http.MethodGet, "/app/app.js", "", http.StatusOK, "console.log(1)", "text/javascript; charset=utf-8"},
+		{"mount root", false, http.MethodGet, "/app", "", http.StatusOK, "<html>home</html>", "text/html; charset=utf-8"},
+		{"directory index", false, http.MethodGet, "/app/docs/", "", http.StatusOK, "<html>docs</html>", "text/html; charset=utf-8"},
+		{"missing without spa", false, http.MethodGet, "/app/users/1", "text/html", http.StatusNotFound, "", ""},
+		{"spa fallback", true, http.MethodGet, "/app/users/1", "text/html,application/xhtml+xml", http.StatusOK, "<html>home</html>", "text/html; charset=utf-8"},
+		{"spa missing asset", true, http.MethodGet, "/app/missing.js", "*/*", http.StatusNotFound, "", ""},
+		{"spa json request", true, http.MethodGet, "/app/users/1", "application/json", http.StatusNotFound, "", ""},
+		{"escape attempt", false, http.MethodGet, "/app/../secret.txt", "", http.StatusNotFound, "", ""},
+		{"post", false, http.MethodPost, "/app/app.js", "", http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := NewStaticHandler(StaticMount{PathPrefix: "/app/", Dir: dir, SPA: tt.spa}, proxy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.URL.Path = tt.path
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+		})
+	}
+}
+
+func TestStaticHandlerETag(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "app.css", "body {}")
+	handler, err := NewStaticHandler(StaticMount{PathPrefix: "/", Dir: dir}, &ProxyServer{config: &Config{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.css", nil))
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("ETag = %q", etag)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/app.css", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("revalidation status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestNewStaticHandlerValidation(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "file.txt", "")
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr string
+	}{
+		{"missing", filepath.Join(dir, "missing"), "no such file"},
+		{"file", filepath.Join(dir, "file.txt"), "not a directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStaticHandler(StaticMount{PathPrefix: "/", Dir: tt.dir}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package godevwatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultStopTimeout is how long a stopped command has to exit before it is killed
+const defaultStopTimeout = 2 * time.Second
+
+// stopSignals are the signals accepted by stop_signal
+var stopSignals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// Validate checks the stop signal and timeout
+func (sc StopConfig) Validate() error {
+	if _, err := sc.signal(); err != nil {
+		return err
+	}
+	if sc.Timeout != nil && *sc.Timeout < 0 {
+		return fmt.Errorf("invalid stop_timeout %s", *sc.Timeout)
+	}
+	return nil
+}
+
+// signal returns the configured stop signal, SIGTERM by default. Names may
+// omit the SIG prefix, and signal numbers are accepted.
+func (sc StopConfig) signal() (syscall.Signal, error) {
+	if sc.Signal == "" {
+		return syscall.SIGTERM, nil
+	}
+	if n, err := strconv.Atoi(sc.Signal); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+
+	name := strings.ToUpper(sc.Signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := stopSignals[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid stop_signal %q", sc.Signal)
+	}
+	return sig, nil
+}
+
+// timeout returns the configured stop timeout or the default. An explicit
+// zero means the process is killed immediately.
+func (sc StopConfig) timeout() time.Duration {
+	if sc.Timeout != nil {
+		return *sc.Timeout
+	}
+	return defaultStopTimeout
+}
+
+// signalName returns the conventional name of a signal, such as SIGINT
+func signalName(sig syscall.Signal) string {
+	for name, s := range stopSignals {
+		if s == sig {
+			return name
+		}
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
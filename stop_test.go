@@ -0,0 +1,142 @@
+package godevwatch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stopTimeout returns a stop_timeout setting
+func stopTimeout(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestStopConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  StopConfig
+		want    syscall.Signal
+		wantErr string
+	}{
+		{"default", StopConfig{}, syscall.SIGTERM, ""},
+		{"name", StopConfig{Signal: "SIGINT"}, syscall.SIGINT, ""},
+		{"without prefix", StopConfig{Signal: "quit"}, syscall.SIGQUIT, ""},
+		{"number", StopConfig{Signal: "10"}, syscall.Signal(10), ""},
+		{"unknown name", StopConfig{Signal: "SIGFOO"}, 0, `invalid stop_signal "SIGFOO"`},
+		{"zero", StopConfig{Signal: "0"}, 0, `invalid stop_signal "0"`},
+		{"negative timeout", StopConfig{Timeout: stopTimeout(-time.Second)}, 0, "invalid stop_timeout -1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig, _ := tt.config.signal(); sig != tt.want {
+				t.Fatalf("signal = %v, want %v", sig, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandKill(t *testing.T) {
+	// Each command reports the signal that stopped it
+	trap := func(sig string) string {
+		return "trap 'echo got " + sig + "; exit 0' " + sig + "; echo ready; while true; do sleep 0.05; done"
+	}
+
+	tests := []struct {
+		name       string
+		command    string
+		stop       StopConfig
+		wantOutput string
+		wantSignal string
+		minElapsed time.Duration
+	}{
+		{"default signal", trap("TERM"), StopConfig{}, "got TERM", "", 0},
+		{"stop signal", trap("INT"), StopConfig{Signal: "SIGINT"}, "got INT", "", 0},
+		{"stop command", trap("USR1"), StopConfig{Command: "kill -USR1 $GODEVWATCH_PID"}, "got USR1", "", 0},
+		{"failed stop command falls back to signal", trap("TERM"), StopConfig{Command: "exit 1"}, "got TERM", "", 0},
+		{"killed immediately", trap("TERM"), StopConfig{Timeout: stopTimeout(0)}, "", "killed", 0},
+		{"killed after timeout", "trap '' TERM; echo ready; while true; do sleep 0.05; done", StopConfig{Timeout: stopTimeout(300 * time.Millisecond)}, "", "killed", 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var output []string
+			ready := make(chan struct{})
+
+			cmd := NewCommand(tt.command)
+			cmd.Stop = tt.stop
+			cmd.OnStdout = func(line string) {
+				if line == "ready" {
+					close(ready)
+					return
+				}
+				mu.Lock()
+				output = append(output, line)
+				mu.Unlock()
+			}
+			go cmd.Run()
+
+			select {
+			case <-ready:
+			case <-time.After(5 * time.Second):
+				t.Fatal("command did not start")
+			}
+
+			start := time.Now()
+			if err := cmd.Kill(); err != nil {
+				t.Fatal(err)
+			}
+			if !cmd.Exited() {
+				t.Fatal("command still running after Kill")
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("stopped after %s, want at least %s", elapsed, tt.minElapsed)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got := strings.Join(output, "\n"); got != tt.wantOutput || cmd.Signal() != tt.wantSignal {
+				t.Fatalf("output %q, signal %q, want %q, %q", got, cmd.Signal(), tt.wantOutput, tt.wantSignal)
+			}
+		})
+	}
+}
+
+func TestCommandKillBeforeRun(t *testing.T) {
+	cmd := NewCommand("echo started > started.log")
+	cmd.Dir = t.TempDir()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Kill() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Kill blocked on a command that was never run")
+	}
+
+	// A stopped command does not start afterwards
+	if err := cmd.Run(); err == nil {
+		t.Fatal("killed command ran")
+	}
+	if _, err := os.Stat(filepath.Join(cmd.Dir, "started.log")); !os.IsNotExist(err) {
+		t.Fatalf("command output exists: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package godevwatch
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// defaultSwapReadyTimeout is how long a new app process has to start accepting requests
+	// before it is swapped in or browsers are reloaded
+	defaultSwapReadyTimeout = 30 * time.Second
+
+	// defaultSwapDrainTimeout is how long requests to the previous app process have to finish
+	defaultSwapDrainTimeout = 10 * time.Second
+)
+
+// Upstream is the proxy side of a blue/green swap of the app
+type Upstream interface {
+	// UpstreamPort returns the port requests for the app are sent to
+	UpstreamPort() int
+
+	// UpstreamReady reports whether the app in process group pgid is accepting requests on port
+	UpstreamReady(port, pgid int) bool
+
+	// SwitchUpstream sends new requests for the app to port
+	SwitchUpstream(port int)
+
+	// DrainUpstream waits for requests to port to finish and closes its remaining connections
+	DrainUpstream(port int, timeout time.Duration)
+}
+
+// Validate checks the swap ports and fills in defaults
+func (sc *SwapConfig) Validate(backendPort int) error {
+	if !sc.Enabled {
+		return nil
+	}
+	if backendPort == 0 {
+		return fmt.Errorf("swap requires backend_port")
+	}
+	if sc.Port == 0 {
+		sc.Port = backendPort + 1
+	}
+	if sc.Port == backendPort {
+		return fmt.Errorf("swap.port must differ from backend_port")
+	}
+	if sc.ReadyTimeout <= 0 {
+		sc.ReadyTimeout = defaultSwapReadyTimeout
+	}
+	if sc.DrainTimeout <= 0 {
+		sc.DrainTimeout = defaultSwapDrainTimeout
+	}
+	return nil
+}
+
+// SetUpstream connects the proxy so rebuilt apps can be swapped in without downtime
+func (pm *ProcessManager) SetUpstream(upstream Upstream) {
+	pm.upstream = upstream
+}
+
+// CanSwap reports whether rebuilt apps are swapped in instead of restarted
+func (pm *ProcessManager) CanSwap() bool {
+	return pm.config.Swap.Enabled && pm.upstream != nil
+}
+
+// Swap starts the rebuilt app next to the running one, on the other port, and
+// moves traffic over once it accepts requests. The previous process is then
+// drained and stopped. If the new process does not become ready the previous
+// one keeps serving.
+func (pm *ProcessManager) Swap(buildID string) error {
+	if err := pm.waitDependencies(appProcessName, pm.config.DependsOn, buildID); err != nil {
+		return err
+	}
+
+	// The port about to be reused may still be held by the app replaced last time
+	pm.stopRetired(buildID)
+
+	current := pm.upstream.UpstreamPort()
+	next := current
+	if pm.app.Running() {
+		next = pm.config.Swap.Port
+		if current == next {
+			next = pm.config.BackendPort
+		}
+		log.Printf("\033[36m[%s] Starting new application on port %d, previous keeps serving on port %d\033[0m\n", buildID, next, current)
+	}
+
+	previous, exited := pm.app.Replace(buildID, next)
+	cmd, _ := pm.app.current()
+	if err := pm.waitUpstream(next, cmd, exited); err != nil {
+		if previous == nil {
+			return err
+		}
+		pm.app.Restore(previous, current)
+		return fmt.Errorf("%w, previous application keeps serving on port %d", err, current)
+	}
+
+	pm.upstream.SwitchUpstream(next)
+	pm.publishReload()
+	if previous == nil {
+		return nil
+	}
+	log.Printf("\033[32m[%s] Switched traffic to port %d\033[0m\n", buildID, next)
+
+	pm.retired = previous
+	go func() {
+		pm.upstream.DrainUpstream(current, pm.config.Swap.DrainTimeout)
+		if !previous.Exited() {
+			previous.Kill()
+			log.Printf("\033[33m[%s] Previous application on port %d stopped\033[0m\n", buildID, current)
+		}
+	}()
+
+	return nil
+}
+
+// stopRetired stops the app replaced by the last swap if it is still draining,
+// so the port it holds can be reused
+func (pm *ProcessManager) stopRetired(buildID string) {
+	if pm.retired == nil {
+		return
+	}
+	if !pm.retired.Exited() {
+		log.Printf("\033[33m[%s] Stopping previous application before reusing its port\033[0m\n", buildID)
+		pm.retired.Kill()
+	}
+	pm.retired = nil
+}
+
+// waitUpstream waits for cmd to accept requests on port, failing early if it exits
+func (pm *ProcessManager) waitUpstream(port int, cmd *Command, exited <-chan struct{}) error {
+	timeout := pm.config.Swap.ReadyTimeout
+	if timeout <= 0 {
+		timeout = defaultSwapReadyTimeout
+	}
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		// Until it has started, anything listening on port is another process
+		if pid := cmd.PID(); pid != 0 && pm.upstream.UpstreamReady(port, pid) {
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("new application exited before accepting requests on port %d", port)
+		case <-deadline:
+			return fmt.Errorf("new application did not accept requests on port %d within %s", port, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// UpstreamPort returns the port of the default backend
+func (ps *ProxyServer) UpstreamPort() int {
+	if backend := ps.findBackend(""); backend != nil {
+		return backend.Port()
+	}
+	return 0
+}
+
+// UpstreamReady reports whether the default backend would accept requests on
+// port from the app in process group pgid
+func (ps *ProxyServer) UpstreamReady(port, pgid int) bool {
+	backend := ps.findBackend("")
+	return backend != nil && backend.IsReadyOnPort(port, pgid)
+}
+
+// SwitchUpstream points the default backend at port
+func (ps *ProxyServer) SwitchUpstream(port int) {
+	if backend := ps.findBackend(""); backend != nil {
+		backend.SetPort(port)
+	}
+}
+
+// DrainUpstream waits for requests to the previous app port to finish
+func (ps *ProxyServer) DrainUpstream(port int, timeout time.Duration) {
+	if backend := ps.findBackend(""); backend != nil {
+		backend.Drain(port, timeout)
+	}
+}
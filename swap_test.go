@@ -0,0 +1,210 @@
+package godevwatch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUpstream is an upstream where an app is ready on a port once its
+// process ID has been written to the ready file for that port
+type fakeUpstream struct {
+	dir      string
+	port     int
+	drained  chan int
+	drainFor time.Duration
+	mu       sync.Mutex
+}
+
+func (u *fakeUpstream) UpstreamPort() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.port
+}
+
+func (u *fakeUpstream) UpstreamReady(port, pgid int) bool {
+	data, err := os.ReadFile(filepath.Join(u.dir, fmt.Sprintf("ready-%d", port)))
+	return err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(pgid)
+}
+
+func (u *fakeUpstream) SwitchUpstream(port int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.port = port
+}
+
+func (u *fakeUpstream) DrainUpstream(port int, timeout time.Duration) {
+	if u.drained != nil {
+		u.drained <- port
+	}
+	time.Sleep(u.drainFor)
+}
+
+// swapCommand runs an app that listens once the rebuilt file exists and next
+// has run, and only after the previous holder of its port has exited
+func swapCommand(dir, next string) string {
+	return fmt.Sprintf("cd %s; if [ -f rebuilt ]; then %s; fi; "+
+		"while [ -f ready-$PORT ] && kill -0 $(cat ready-$PORT) 2>/dev/null; do sleep 0.05; done; "+
+		"echo $$ > ready-$PORT; sleep 30", dir, next)
+}
+
+// newSwapManager creates a process manager swapping between ports 8080 and 8081
+func newSwapManager(t *testing.T, runCmd string, upstream *fakeUpstream) *ProcessManager {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.BackendPort = 8080
+	config.RunCmd = runCmd
+	config.Swap = SwapConfig{Enabled: true, ReadyTimeout: time.Second}
+	if err := config.Swap.Validate(config.BackendPort); err != nil {
+		t.Fatal(err)
+	}
+
+	pm, err := NewProcessManager(config, NewBuildTracker(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.SetUpstream(upstream)
+	t.Cleanup(func() {
+		pm.StopCurrentProcess("test")
+		pm.stopRetired("test")
+	})
+	return pm
+}
+
+func TestSwapConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      SwapConfig
+		backendPort int
+		want        SwapConfig
+		wantErr     string
+	}{
+		{"disabled", SwapConfig{}, 0, SwapConfig{}, ""},
+		{"defaults", SwapConfig{Enabled: true}, 8080,
+			SwapConfig{Enabled: true, Port: 8081, ReadyTimeout: defaultSwapReadyTimeout, DrainTimeout: defaultSwapDrainTimeout}, ""},
+		{"explicit port", SwapConfig{Enabled: true, Port: 9000, ReadyTimeout: time.Second, DrainTimeout: time.Second}, 8080,
+			SwapConfig{Enabled: true, Port: 9000, ReadyTimeout: time.Second, DrainTimeout: time.Second}, ""},
+		{"no backend port", SwapConfig{Enabled: true}, 0, SwapConfig{}, "swap requires backend_port"},
+		{"same port", SwapConfig{Enabled: true, Port: 8080}, 8080, SwapConfig{}, "swap.port must differ from backend_port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate(tt.backendPort)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.config != tt.want {
+				t.Fatalf("config = %+v, want %+v", tt.config, tt.want)
+			}
+		})
+	}
+}
+
+func TestSwap(t *testing.T) {
+	tests := []struct {
+		name     string
+		next     string
+		wantPort int
+		wantErr  string
+	}{
+		{"swapped to the other port", ":", 8081, ""},
+		{"new app exits", "exit 1", 8080, "new application exited before accepting requests on port 8081, previous application keeps serving on port 8080"},
+		{"new app not ready", "sleep 30", 8080, "new application did not accept requests on port 8081 within 1s, previous application keeps serving on port 8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			upstream := &fakeUpstream{dir: dir, port: 8080, drained: make(chan int, 1)}
+			pm := newSwapManager(t, swapCommand(dir, tt.next), upstream)
+
+			// The first app starts on the backend port with nothing to drain
+			if err := pm.Swap("build-1"); err != nil {
+				t.Fatal(err)
+			}
+			first, _ := pm.app.current()
+			if upstream.UpstreamPort() != 8080 || len(upstream.drained) != 0 {
+				t.Fatalf("first start on port %d", upstream.UpstreamPort())
+			}
+
+			writeFile(t, dir, "rebuilt", "")
+			err := pm.Swap("build-2")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if port := upstream.UpstreamPort(); port != tt.wantPort {
+				t.Fatalf("upstream port = %d, want %d", port, tt.wantPort)
+			}
+
+			current, _ := pm.app.current()
+			if tt.wantErr != "" {
+				// The previous app keeps serving
+				if current != first || first.Exited() {
+					t.Fatal("previous application not restored")
+				}
+				return
+			}
+
+			// The previous app is drained, then stopped
+			select {
+			case port := <-upstream.drained:
+				if port != 8080 {
+					t.Fatalf("drained port %d, want 8080", port)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("previous application not drained")
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for !first.Exited() && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+			if current == first || !first.Exited() {
+				t.Fatal("previous application still running")
+			}
+		})
+	}
+}
+
+func TestSwapWhileDraining(t *testing.T) {
+	dir := t.TempDir()
+	upstream := &fakeUpstream{dir: dir, port: 8080, drained: make(chan int, 2), drainFor: time.Minute}
+	pm := newSwapManager(t, swapCommand(dir, ":"), upstream)
+
+	var apps []*Command
+	for i, wantPort := range []int{8080, 8081, 8080} {
+		if err := pm.Swap(fmt.Sprintf("build-%d", i+1)); err != nil {
+			t.Fatalf("swap %d: %v", i+1, err)
+		}
+		if port := upstream.UpstreamPort(); port != wantPort {
+			t.Fatalf("swap %d: upstream port = %d, want %d", i+1, port, wantPort)
+		}
+		app, _ := pm.app.current()
+		apps = append(apps, app)
+	}
+
+	// Swapping back stopped the first app instead of waiting for its drain
+	if !apps[0].Exited() {
+		t.Fatal("first application still running")
+	}
+	if apps[1].Exited() || apps[2].Exited() {
+		t.Fatal("draining or current application stopped")
+	}
+}
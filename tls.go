@@ -0,0 +1,223 @@
+package godevwatch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	caCertFile   = "ca.pem"
+	caKeyFile    = "ca-key.pem"
+	leafCertFile = "localhost.pem"
+	leafKeyFile  = "localhost-key.pem"
+)
+
+// DefaultCertDir returns the directory certificates are cached in when none is configured
+func DefaultCertDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join("tmp", ".certs")
+	}
+	return filepath.Join(dir, "godevwatch", "certs")
+}
+
+// LoadOrCreateCertificates returns a TLS configuration backed by a local
+// development CA and a leaf certificate for localhost and the given hostnames.
+// Both are cached in dir; the leaf is regenerated when it expires or the
+// hostnames change, while the CA is kept so it only has to be trusted once.
+func LoadOrCreateCertificates(dir string, hostnames []string) (*tls.Config, error) {
+	if dir == "" {
+		dir = DefaultCertDir()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := certHosts(hostnames)
+	leaf, err := loadLeaf(dir, caCert, hosts)
+	if err != nil {
+		leaf, err = createLeaf(dir, caCert, caKey, hosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{leaf},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// certHosts returns the sorted, de-duplicated list of names the leaf certificate covers
+func certHosts(hostnames []string) []string {
+	seen := map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true}
+	for _, h := range hostnames {
+		seen[h] = true
+	}
+
+	hosts := make([]string, 0, len(seen))
+	for h := range seen {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// loadOrCreateCA loads the cached development CA or generates a new one
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, caCertFile)
+	keyPath := filepath.Join(dir, caKeyFile)
+
+	if pair, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if err == nil && ok && time.Now().Before(cert.NotAfter) {
+			return cert, key, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{Organization: []string{"godevwatch"}, CommonName: "godevwatch development CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	if err := writeCertAndKey(certPath, keyPath, der, key); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	log.Printf("\033[33mCreated development CA at %s\033[0m\n", certPath)
+	log.Println("\033[33mAdd it to your system or browser trust store to avoid certificate warnings\033[0m")
+
+	return cert, key, nil
+}
+
+// loadLeaf loads the cached leaf certificate if it is still valid for hosts and signed by ca
+func loadLeaf(dir string, ca *x509.Certificate, hosts []string) (tls.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, leafCertFile), filepath.Join(dir, leafKeyFile))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if time.Now().Add(24 * time.Hour).After(cert.NotAfter) {
+		return tls.Certificate{}, fmt.Errorf("certificate expires soon")
+	}
+	if err := cert.CheckSignatureFrom(ca); err != nil {
+		return tls.Certificate{}, err
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return pair, nil
+}
+
+// createLeaf generates a leaf certificate for hosts signed by the development CA
+func createLeaf(dir string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{Organization: []string{"godevwatch"}, CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// Browsers reject leaf certificates valid for longer than 825 days
+		NotAfter:    time.Now().AddDate(0, 0, 825),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	certPath := filepath.Join(dir, leafCertFile)
+	keyPath := filepath.Join(dir, leafKeyFile)
+	if err := writeCertAndKey(certPath, keyPath, der, key); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// writeCertAndKey writes a DER certificate and its private key as PEM files
+func writeCertAndKey(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	return nil
+}
+
+// randomSerial returns a random certificate serial number
+func randomSerial() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
@@ -0,0 +1,95 @@
+package godevwatch
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCertHosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostnames []string
+		want      []string
+	}{
+		{"defaults", nil, []string{"127.0.0.1", "::1", "localhost"}},
+		{"extra hostname", []string{"app.test"}, []string{"127.0.0.1", "::1", "app.test", "localhost"}},
+		{"duplicates", []string{"localhost", "app.test", "app.test"}, []string{"127.0.0.1", "::1", "app.test", "localhost"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := certHosts(tt.hostnames); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("certHosts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// readCert reads a PEM certificate file from the certificate directory
+func readCert(t *testing.T, dir, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLoadOrCreateCertificates(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := LoadOrCreateCertificates(dir, []string{"app.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := readCert(t, dir, caCertFile)
+	leaf := readCert(t, dir, leafCertFile)
+
+	// The served certificate is signed by the CA for every host
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		t.Fatal("invalid CA certificate")
+	}
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "::1", "app.test"} {
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: host}); err != nil {
+			t.Errorf("%s: %v", host, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, caKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("CA key permissions: %v %v", info, err)
+	}
+
+	tests := []struct {
+		name        string
+		hostnames   []string
+		leafChanged bool
+	}{
+		{"same hostnames reuse the certificate", []string{"app.test"}, false},
+		{"new hostname regenerates the certificate", []string{"app.test", "api.test"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadOrCreateCertificates(dir, tt.hostnames); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(readCert(t, dir, caCertFile), ca) {
+				t.Error("CA regenerated")
+			}
+			current := readCert(t, dir, leafCertFile)
+			if changed := !bytes.Equal(current, leaf); changed != tt.leafChanged {
+				t.Errorf("certificate changed = %v, want %v", changed, tt.leafChanged)
+			}
+			leaf = current
+		})
+	}
+}
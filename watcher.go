@@ -5,11 +5,21 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+)
+
+const (
+	// buildOutputRate is the most build output lines forwarded to clients per second
+	buildOutputRate = 100
+
+	// OnChangeAbort aborts a running build rule when files change, then builds again
+	OnChangeAbort = "abort"
 
-	"github.com/fsnotify/fsnotify"
+	// OnChangeQueue lets a running build rule finish, then restarts the build
+	OnChangeQueue = "queue"
 )
 
 // FileWatcher watches files and triggers builds
@@ -17,39 +27,97 @@ type FileWatcher struct {
 	config         *Config
 	buildTracker   *BuildTracker
 	processManager *ProcessManager
-	watcher        *fsnotify.Watcher
+	cache          *buildCache
+	changes        *changeDetector
+	watcher        EventSource
 	mu             sync.Mutex
-	debounceTime   time.Duration
-	buildTrigger   chan []string // Changed files that triggered the build
+	debounce       *debouncer
+	buildTrigger   chan struct{} // Signals that changes are queued
+	queued         bool
+	queuedFiles    []string // Changed files for the next build, nil for all rules
+	restartQueued  bool     // An app restart waits for the build goroutine
 	currentBuild   *Command
+	currentRule    BuildRule
+	paused         bool
+	pendingChanges []string
 	stopChan       chan bool
 }
 
 // NewFileWatcher creates a new file watcher
 func NewFileWatcher(config *Config, buildTracker *BuildTracker) (*FileWatcher, error) {
-	watcher, err := fsnotify.NewWatcher()
+	if err := config.Watcher.Validate(); err != nil {
+		return nil, err
+	}
+	watcher, err := newEventSource(config.Watcher)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create watcher: %w", err)
+		return nil, err
 	}
 
-	processManager := NewProcessManager(config, buildTracker)
+	if err := config.Debounce.Validate(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	for _, rule := range config.BuildRules {
+		if err := rule.Stop.Validate(); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("build rule %q: %w", rule.Name, err)
+		}
+		if err := rule.Debounce.Validate(); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("build rule %q: %w", rule.Name, err)
+		}
+		switch rule.OnChange {
+		case "", OnChangeAbort, OnChangeQueue:
+		default:
+			watcher.Close()
+			return nil, fmt.Errorf("build rule %q: invalid on_change %q, expected %q or %q", rule.Name, rule.OnChange, OnChangeAbort, OnChangeQueue)
+		}
+		// Without outputs a skipped rule could leave nothing behind to run
+		if len(rule.Inputs) > 0 && len(rule.Outputs) == 0 {
+			watcher.Close()
+			return nil, fmt.Errorf("build rule %q: inputs require at least one output", rule.Name)
+		}
+	}
 
-	return &FileWatcher{
+	processManager, err := NewProcessManager(config, buildTracker)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	fw := &FileWatcher{
 		config:         config,
 		buildTracker:   buildTracker,
 		processManager: processManager,
+		cache:          newBuildCache(config.BuildStatusDir),
+		changes:        newChangeDetector(),
 		watcher:        watcher,
-		debounceTime:   100 * time.Millisecond,
-		buildTrigger:   make(chan []string, 1), // Non-blocking trigger with changed files
+		buildTrigger:   make(chan struct{}, 1), // Non-blocking trigger, the changes are queued
 		stopChan:       make(chan bool),
-	}, nil
+	}
+	fw.debounce = newDebouncer(fw.triggerChanges)
+	return fw, nil
 }
 
 // Start starts watching files
 func (fw *FileWatcher) Start() error {
 	// Add directories to watch based on patterns
+	start := time.Now()
 	if err := fw.addWatchPaths(); err != nil {
-		return err
+		if fw.config.Watcher.Backend != WatcherAuto || !isWatchLimit(err) {
+			return err
+		}
+
+		// Too many directories for the system's watch limit
+		log.Printf("\033[33mFile watch limit reached (%v), polling every %s instead\033[0m\n", err, fw.config.Watcher.PollInterval)
+		fw.watcher.Close()
+		fw.watcher = newPollingSource(fw.config.Watcher.PollInterval)
+		if err := fw.addWatchPaths(); err != nil {
+			return err
+		}
+	}
+	if fw.config.Verbose {
+		log.Printf("\033[90mRecorded the contents of %d watched files in %s\033[0m\n", fw.changes.Len(), time.Since(start).Round(time.Millisecond))
 	}
 
 	// Start build processor
@@ -58,13 +126,17 @@ func (fw *FileWatcher) Start() error {
 	// Start file event processor
 	go fw.processFileEvents()
 
-	// Trigger initial build with all rules
-	fw.triggerBuild(nil)
+	// Start services in order, then trigger initial build with all rules
+	go func() {
+		fw.processManager.StartServices()
+		fw.triggerBuild(nil)
+	}()
 
 	return nil
 }
 
-// addWatchPaths adds directories to watch based on config patterns
+// addWatchPaths adds directories to watch based on config patterns, recording
+// the contents of watched files so unchanged ones can be ignored
 func (fw *FileWatcher) addWatchPaths() error {
 	// Get current working directory
 	cwd, err := os.Getwd()
@@ -79,12 +151,14 @@ func (fw *FileWatcher) addWatchPaths() error {
 		}
 
 		if !info.IsDir() {
+			if fw.shouldWatch(path) {
+				fw.changes.Seed(path)
+			}
 			return nil
 		}
 
 		// Skip hidden directories, vendor, node_modules, etc.
-		name := filepath.Base(path)
-		if strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules" || name == "tmp" {
+		if skipDir(filepath.Base(path)) {
 			return filepath.SkipDir
 		}
 
@@ -92,15 +166,16 @@ func (fw *FileWatcher) addWatchPaths() error {
 	})
 }
 
+// skipDir reports whether a directory is left out of watching and input hashing
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules" || name == "tmp"
+}
+
 // processFileEvents processes file system events
 func (fw *FileWatcher) processFileEvents() {
-	var debounceTimer *time.Timer
-	var changedFiles []string
-	var filesMu sync.Mutex
-
 	for {
 		select {
-		case event, ok := <-fw.watcher.Events:
+		case event, ok := <-fw.watcher.Events():
 			if !ok {
 				return
 			}
@@ -110,27 +185,21 @@ func (fw *FileWatcher) processFileEvents() {
 				continue
 			}
 
-			// Track changed file
-			filesMu.Lock()
-			changedFiles = append(changedFiles, event.Name)
-			filesMu.Unlock()
-
-			// Debounce rapid file changes
-			if debounceTimer != nil {
-				debounceTimer.Stop()
+			// Hold changes while paused, they are built on resume
+			fw.mu.Lock()
+			if fw.paused {
+				fw.pendingChanges = append(fw.pendingChanges, event.Name)
+				pending := len(fw.pendingChanges)
+				fw.mu.Unlock()
+				fw.buildTracker.Events().Publish(MessageWatcherStatus, WatcherStatusData{Paused: true, PendingChanges: pending})
+				continue
 			}
+			fw.mu.Unlock()
 
-			debounceTimer = time.AfterFunc(fw.debounceTime, func() {
-				filesMu.Lock()
-				files := make([]string, len(changedFiles))
-				copy(files, changedFiles)
-				changedFiles = nil
-				filesMu.Unlock()
-
-				fw.triggerBuild(files)
-			})
+			// Debounce rapid file changes
+			fw.debounce.Add(event.Name, fw.debounceFor(event.Name))
 
-		case err, ok := <-fw.watcher.Errors:
+		case err, ok := <-fw.watcher.Errors():
 			if !ok {
 				return
 			}
@@ -142,13 +211,49 @@ func (fw *FileWatcher) processFileEvents() {
 	}
 }
 
+// triggerChanges triggers a build for the files whose contents changed
+func (fw *FileWatcher) triggerChanges(files []string) {
+	if files = fw.changedContents(files); len(files) > 0 {
+		fw.triggerBuild(files)
+	}
+}
+
+// changedContents returns the files whose contents changed, each listed once.
+// Files that were saved or replaced without changing are dropped.
+func (fw *FileWatcher) changedContents(files []string) []string {
+	seen := make(map[string]bool, len(files))
+	var changed []string
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		if fw.changes.Changed(file) {
+			changed = append(changed, file)
+		} else if fw.config.Verbose {
+			checked, suppressed := fw.changes.Stats()
+			log.Printf("\033[90mIgnoring %s: contents unchanged (%d of %d changed files suppressed)\033[0m\n", relativePaths([]string{file})[0], suppressed, checked)
+		}
+	}
+	return changed
+}
+
 // shouldWatch checks if a file matches any watch pattern in build rules
 func (fw *FileWatcher) shouldWatch(path string) bool {
 	for _, rule := range fw.config.BuildRules {
-		for _, pattern := range rule.Watch {
-			if fw.matchesPattern(path, pattern) {
-				return true
-			}
+		if fw.ruleWatches(rule, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleWatches checks if a file matches one of a build rule's watch patterns
+func (fw *FileWatcher) ruleWatches(rule BuildRule, path string) bool {
+	for _, pattern := range rule.Watch {
+		if fw.matchesPattern(path, pattern) {
+			return true
 		}
 	}
 	return false
@@ -173,39 +278,171 @@ func (fw *FileWatcher) matchesPattern(path, pattern string) bool {
 	return false
 }
 
-// triggerBuild triggers a build, aborting current one if running
+// triggerBuild queues a build for the changed files, merged with any changes
+// already waiting. A running build is aborted unless its current rule has
+// on_change: queue, in which case the build restarts once that rule finishes.
 func (fw *FileWatcher) triggerBuild(changedFiles []string) {
+	fw.mu.Lock()
+	fw.queueChanges(changedFiles)
+	var aborted *Command
+	if fw.currentBuild != nil {
+		if fw.currentRule.OnChange == OnChangeQueue {
+			log.Printf("\033[36mChanges queued until rule %s finishes\033[0m\n", fw.currentRule.Name)
+		} else {
+			aborted = fw.currentBuild
+			fw.currentBuild = nil
+		}
+	}
+	fw.mu.Unlock()
+
+	// Stopping the build can take up to its stop timeout, so it is done unlocked
+	if aborted != nil {
+		log.Println("\033[33mAborting current build due to file change...\033[0m")
+
+		// Mark current build as aborted first
+		currentBuildID, _ := fw.buildTracker.GetCurrentBuildID()
+		if currentBuildID != "" {
+			fw.buildTracker.SetStatus(currentBuildID, BuildStatusAborted)
+		}
+		aborted.Kill()
+	}
+
 	select {
-	case fw.buildTrigger <- changedFiles:
+	case fw.buildTrigger <- struct{}{}:
 		// Build triggered successfully
 	default:
-		// Build already pending, skip
+		// Build already pending, the changes were merged into it
+	}
+}
+
+// queueChanges merges changed files into the queued build, the caller must hold
+// mu. A nil change set (the initial build) or an empty one (a manual rebuild)
+// runs every rule, so it takes precedence over a list of files.
+func (fw *FileWatcher) queueChanges(changedFiles []string) {
+	switch {
+	case !fw.queued:
+		fw.queued = true
+		fw.queuedFiles = changedFiles
+	case fw.queuedFiles == nil || changedFiles == nil:
+		fw.queuedFiles = nil
+	case len(fw.queuedFiles) == 0 || len(changedFiles) == 0:
+		fw.queuedFiles = []string{}
+	default:
+		for _, file := range changedFiles {
+			if !slices.Contains(fw.queuedFiles, file) {
+				fw.queuedFiles = append(fw.queuedFiles, file)
+			}
+		}
+	}
+}
+
+// takeQueued returns the queued changes and empties the queue
+func (fw *FileWatcher) takeQueued() ([]string, bool) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	files, queued := fw.queuedFiles, fw.queued
+	fw.queuedFiles = nil
+	fw.queued = false
+	return files, queued
+}
+
+// Rebuild restarts the application after running every build rule
+func (fw *FileWatcher) Rebuild() {
+	log.Println("\033[36mRebuild requested\033[0m")
+	// A non-nil change set stops the running app like a file change would
+	fw.triggerBuild([]string{})
+}
+
+// Logs returns the buffered output of the running application
+func (fw *FileWatcher) Logs() *LogBuffer {
+	return fw.processManager.Logs()
+}
+
+// SetUpstream connects the proxy so rebuilt apps can be swapped in without downtime
+func (fw *FileWatcher) SetUpstream(upstream Upstream) {
+	fw.processManager.SetUpstream(upstream)
+}
+
+// ProcessLogs returns the buffered output of the named managed process
+func (fw *FileWatcher) ProcessLogs(name string) (*LogBuffer, bool) {
+	return fw.processManager.ProcessLogs(name)
+}
+
+// RestartApp restarts the application without rebuilding it. The restart runs
+// on the build goroutine so it never overlaps a build starting the app.
+func (fw *FileWatcher) RestartApp() {
+	fw.mu.Lock()
+	fw.restartQueued = true
+	fw.mu.Unlock()
+
+	select {
+	case fw.buildTrigger <- struct{}{}:
+	default:
+		// A build is pending, the restart follows it
+	}
+}
+
+// restartApp restarts the application if a restart was requested
+func (fw *FileWatcher) restartApp() {
+	fw.mu.Lock()
+	requested := fw.restartQueued
+	fw.restartQueued = false
+	fw.mu.Unlock()
+	if !requested {
+		return
+	}
+
+	buildID, err := fw.buildTracker.GetCurrentBuildID()
+	if err != nil {
+		log.Printf("Failed to restart application: %v", err)
+		return
+	}
+	log.Printf("\033[36m[%s] Restarting application...\033[0m\n", buildID)
+	if err := fw.processManager.Restart(buildID); err != nil {
+		log.Printf("Failed to restart application: %v", err)
 	}
 }
 
+// SetPaused pauses or resumes reacting to file changes. Changes seen while
+// paused trigger a single build on resume.
+func (fw *FileWatcher) SetPaused(paused bool) {
+	fw.mu.Lock()
+	if fw.paused == paused {
+		fw.mu.Unlock()
+		return
+	}
+	fw.paused = paused
+	pending := fw.pendingChanges
+	fw.pendingChanges = nil
+	fw.mu.Unlock()
+
+	if paused {
+		log.Println("\033[33mFile watching paused\033[0m")
+	} else {
+		log.Println("\033[36mFile watching resumed\033[0m")
+		fw.triggerChanges(pending)
+	}
+
+	fw.buildTracker.Events().Publish(MessageWatcherStatus, WatcherStatusData{Paused: paused})
+}
+
+// Status reports whether file watching is paused and how many changes are waiting
+func (fw *FileWatcher) Status() WatcherStatusData {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return WatcherStatusData{Paused: fw.paused, PendingChanges: len(fw.pendingChanges)}
+}
+
 // processBuildTriggers processes build triggers (abort-and-restart pattern like watchexec)
 func (fw *FileWatcher) processBuildTriggers() {
 	for {
 		select {
-		case changedFiles := <-fw.buildTrigger:
-			// If there's a current build command running, abort it
-			fw.mu.Lock()
-			if fw.currentBuild != nil {
-				log.Println("\033[33mAborting current build due to file change...\033[0m")
-
-				// Mark current build as aborted first
-				currentBuildID, _ := fw.buildTracker.GetCurrentBuildID()
-				if currentBuildID != "" {
-					fw.buildTracker.SetStatus(currentBuildID, BuildStatusAborted)
-				}
-
-				fw.currentBuild.Kill()
-				fw.currentBuild = nil
+		case <-fw.buildTrigger:
+			// Execute new build with the changed files queued since the last one
+			if changedFiles, ok := fw.takeQueued(); ok {
+				fw.executeBuild(changedFiles)
 			}
-			fw.mu.Unlock()
-
-			// Execute new build with changed files
-			fw.executeBuild(changedFiles)
+			fw.restartApp()
 
 		case <-fw.stopChan:
 			return
@@ -217,7 +454,9 @@ func (fw *FileWatcher) processBuildTriggers() {
 func (fw *FileWatcher) executeBuild(changedFiles []string) {
 	// Step 1: Stop the running application FIRST (before creating new build)
 	// This ensures the port is freed before we try to start the new build
-	if changedFiles != nil { // Only stop if this is a rebuild (not initial build)
+	// With swap enabled, or stop_before_build off, the running app keeps serving while building
+	stopAfterBuild := !fw.config.StopBeforeBuild && !fw.processManager.CanSwap()
+	if changedFiles != nil && fw.config.StopBeforeBuild && !fw.processManager.CanSwap() { // Only stop if this is a rebuild (not initial build)
 		tempBuildID := "stopping"
 		if err := fw.processManager.StopCurrentProcess(tempBuildID); err != nil {
 			log.Printf("Failed to stop previous process: %v", err)
@@ -232,6 +471,7 @@ func (fw *FileWatcher) executeBuild(changedFiles []string) {
 	}
 
 	log.Printf("\n\033[36m[%s] Starting build...\033[0m\n", buildID)
+	start := time.Now()
 
 	// Set building status
 	if err := fw.buildTracker.SetStatus(buildID, BuildStatusBuilding); err != nil {
@@ -248,36 +488,117 @@ func (fw *FileWatcher) executeBuild(changedFiles []string) {
 		return
 	}
 
+	events := fw.buildTracker.Events()
+	ruleNames := make([]string, len(rulesToRun))
+	for i, rule := range rulesToRun {
+		ruleNames[i] = rule.Name
+	}
+	events.Publish(MessageBuildStarted, BuildStartedData{
+		BuildID:      buildID,
+		Rules:        ruleNames,
+		ChangedFiles: relativePaths(changedFiles),
+	})
+
 	// Step 4: Execute each matching build rule in order
-	for _, rule := range rulesToRun {
-		log.Printf("\033[36m[%s] Running rule: %s\033[0m\n", buildID, rule.Name)
+	// A manual rebuild (an empty change set) runs every rule, even if cached
+	useCache := !fw.config.NoCache && (changedFiles == nil || len(changedFiles) > 0)
+	// The project is listed once for all rules, and again after a rule ran
+	// since it may have created inputs of the rules after it
+	var listing *projectFiles
+	ran := false
+	for i, rule := range rulesToRun {
+		// Skip rules whose inputs are unchanged since their last successful run
+		inputHash := ""
+		if len(rule.Inputs) > 0 {
+			var hash string
+			var err error
+			if listing == nil {
+				listing, err = listProjectFiles()
+			}
+			if err == nil {
+				hash, err = fw.hashInputs(rule, listing)
+			}
+			if err != nil {
+				log.Printf("\033[33m[%s] Failed to hash inputs of %s: %v\033[0m\n", buildID, rule.Name, err)
+			} else {
+				inputHash = hash
+			}
+		}
+		if inputHash != "" && useCache && fw.cache.Fresh(rule, inputHash) {
+			log.Printf("\033[32m[%s] Skipping rule: %s (inputs unchanged)\033[0m\n", buildID, rule.Name)
+			continue
+		}
 
+		log.Printf("\033[36m[%s] Running rule: %s\033[0m\n", buildID, rule.Name)
+		events.Publish(MessageRuleStarted, RuleStartedData{
+			BuildID: buildID,
+			Rule:    rule.Name,
+			Index:   i,
+			Total:   len(rulesToRun),
+		})
+
+		ruleName := rule.Name
+		output := newOutputLimiter(buildOutputRate, func(dropped int) {
+			events.Publish(MessageBuildOutput, BuildOutputData{
+				BuildID: buildID,
+				Rule:    ruleName,
+				Stream:  "stderr",
+				Line:    skippedLine(dropped),
+				Dropped: dropped,
+			})
+		})
+		publishOutput := func(stream, line string) {
+			if output.Allow() {
+				events.Publish(MessageBuildOutput, BuildOutputData{BuildID: buildID, Rule: ruleName, Stream: stream, Line: line})
+			}
+		}
 		buildCmd := NewCommand(rule.Command)
+		buildCmd.Name = rule.Name
+		buildCmd.Stop = rule.Stop
 		buildCmd.OnStdout = func(line string) {
 			fmt.Println(line)
+			publishOutput("stdout", line)
 		}
 		buildCmd.OnStderr = func(line string) {
 			fmt.Fprintln(os.Stderr, line)
+			publishOutput("stderr", line)
 		}
 
 		// Track current build so it can be aborted
 		fw.mu.Lock()
 		fw.currentBuild = buildCmd
+		fw.currentRule = rule
 		fw.mu.Unlock()
 
-		if err := buildCmd.Run(); err != nil {
+		err := buildCmd.Run()
+		output.Flush()
+		if err != nil {
 			// Check if it was aborted vs actual failure
 			fw.mu.Lock()
 			wasAborted := fw.currentBuild == nil
 			fw.mu.Unlock()
 
+			finished := BuildFinishedData{
+				BuildID:    buildID,
+				DurationMs: durationMs(start),
+				Rule:       rule.Name,
+			}
 			if wasAborted {
+				// The next build also covers the changes this one did not finish
+				fw.mu.Lock()
+				fw.queueChanges(changedFiles)
+				fw.mu.Unlock()
+
 				log.Printf("\033[33m[%s] Build aborted\033[0m\n", buildID)
 				fw.buildTracker.SetStatus(buildID, BuildStatusAborted)
+				finished.Status = string(BuildStatusAborted)
 			} else {
 				log.Printf("\033[31m[%s] Build failed: %v\033[0m\n", buildID, err)
 				fw.buildTracker.SetStatus(buildID, BuildStatusFailed)
+				finished.Status = string(BuildStatusFailed)
+				finished.Error = err.Error()
 			}
+			events.Publish(MessageBuildFinished, finished)
 
 			fw.mu.Lock()
 			fw.currentBuild = nil
@@ -285,25 +606,83 @@ func (fw *FileWatcher) executeBuild(changedFiles []string) {
 			return
 		}
 
-		// Clear current build tracking after each rule
+		if inputHash != "" {
+			if err := fw.cache.Record(rule, inputHash); err != nil {
+				log.Printf("\033[33m[%s] %v\033[0m\n", buildID, err)
+			}
+		}
+		ran = true
+		listing = nil
+
+		// Clear current build tracking after each rule. Changes queued while
+		// it ran make the rest of the build stale, so it starts over with them.
 		fw.mu.Lock()
 		fw.currentBuild = nil
+		restart := fw.queued
+		if restart {
+			fw.queueChanges(changedFiles)
+		}
 		fw.mu.Unlock()
+
+		if restart {
+			log.Printf("\033[33m[%s] Build restarting with queued changes\033[0m\n", buildID)
+			fw.buildTracker.SetStatus(buildID, BuildStatusAborted)
+			events.Publish(MessageBuildFinished, BuildFinishedData{
+				BuildID:    buildID,
+				Status:     string(BuildStatusAborted),
+				DurationMs: durationMs(start),
+				Rule:       rule.Name,
+			})
+			return
+		}
 	}
 
 	// Step 5: Build succeeded - clean up status files
 	fw.buildTracker.CleanupOldFailed(buildID)
 	fw.buildTracker.ClearBuild(buildID)
 
-	log.Printf("\033[32m[%s] Build succeeded\033[0m\n", buildID)
+	if ran {
+		log.Printf("\033[32m[%s] Build succeeded\033[0m\n", buildID)
+	} else {
+		log.Printf("\033[32m[%s] Build up to date, every rule was skipped\033[0m\n", buildID)
+	}
+	events.Publish(MessageBuildFinished, BuildFinishedData{
+		BuildID:    buildID,
+		Status:     "succeeded",
+		DurationMs: durationMs(start),
+	})
+
+	// Nothing was rebuilt, so the running app is already up to date
+	if !ran && changedFiles != nil && (fw.config.RunCmd == "" || fw.processManager.AppRunning()) {
+		return
+	}
+
+	// The last good version kept serving during the build, stop it now
+	if changedFiles != nil && stopAfterBuild {
+		if err := fw.processManager.StopCurrentProcess(buildID); err != nil {
+			log.Printf("Failed to stop previous process: %v", err)
+		}
+	}
 
-	// Step 6: Run the application (port should be free now)
-	if fw.config.RunCmd != "" {
+	// Step 6: Restart services that depend on the build, then run the application (port should be free now)
+	if changedFiles != nil {
+		fw.processManager.RestartServices(buildID)
+	}
+	if fw.config.RunCmd != "" && fw.processManager.CanSwap() {
+		if err := fw.processManager.Swap(buildID); err != nil {
+			log.Printf("\033[31m[%s] Swap failed: %v\033[0m\n", buildID, err)
+		} else {
+			log.Printf("\033[32m[%s] Application started\033[0m\n", buildID)
+		}
+	} else if fw.config.RunCmd != "" {
 		if err := fw.processManager.RunProcess(buildID); err != nil {
 			log.Printf("Failed to start application: %v", err)
 		} else {
 			log.Printf("\033[32m[%s] Application started\033[0m\n", buildID)
+			fw.processManager.ReloadWhenReady(buildID)
 		}
+	} else {
+		fw.processManager.ReloadWhenReady(buildID)
 	}
 }
 
@@ -319,11 +698,8 @@ func (fw *FileWatcher) determineRulesToRun(changedFiles []string) []BuildRule {
 
 	for _, file := range changedFiles {
 		for i, rule := range fw.config.BuildRules {
-			for _, pattern := range rule.Watch {
-				if fw.matchesPattern(file, pattern) {
-					ruleMatches[i] = true
-					break
-				}
+			if fw.ruleWatches(rule, file) {
+				ruleMatches[i] = true
 			}
 		}
 	}
@@ -339,17 +715,102 @@ func (fw *FileWatcher) determineRulesToRun(changedFiles []string) []BuildRule {
 	return rulesToRun
 }
 
+// relativePaths makes paths relative to the working directory using forward slashes
+func relativePaths(paths []string) []string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return paths
+	}
+
+	rel := make([]string, len(paths))
+	for i, path := range paths {
+		rel[i] = path
+		if r, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel[i] = filepath.ToSlash(r)
+		}
+	}
+	return rel
+}
+
+// outputLimiter allows up to rate output lines per second to be published so
+// a noisy build or process cannot flood clients, reporting how many it skipped
+type outputLimiter struct {
+	rate        int
+	skipped     func(dropped int)
+	windowStart time.Time
+	sent        int
+	dropped     int
+	mu          sync.Mutex
+}
+
+// newOutputLimiter creates an output limiter that calls skipped with the
+// number of lines dropped in each window that dropped any
+func newOutputLimiter(rate int, skipped func(dropped int)) *outputLimiter {
+	return &outputLimiter{
+		rate:        rate,
+		skipped:     skipped,
+		windowStart: time.Now(),
+	}
+}
+
+// Allow reports whether another line may be published, counting it as dropped if not
+func (ol *outputLimiter) Allow() bool {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+
+	if time.Since(ol.windowStart) >= time.Second {
+		ol.flushDropped()
+		ol.windowStart = time.Now()
+		ol.sent = 0
+	}
+
+	if ol.sent >= ol.rate {
+		ol.dropped++
+		return false
+	}
+	ol.sent++
+	return true
+}
+
+// Flush reports any lines dropped in the current window
+func (ol *outputLimiter) Flush() {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+	ol.flushDropped()
+}
+
+// flushDropped reports dropped lines, the caller must hold mu
+func (ol *outputLimiter) flushDropped() {
+	if ol.dropped == 0 {
+		return
+	}
+	ol.skipped(ol.dropped)
+	ol.dropped = 0
+}
+
+// skippedLine is the output line standing in for dropped lines
+func skippedLine(dropped int) string {
+	return fmt.Sprintf("... %d lines skipped", dropped)
+}
+
+// durationMs returns the milliseconds elapsed since start
+func durationMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
 // Stop stops the file watcher
 func (fw *FileWatcher) Stop() error {
 	close(fw.stopChan)
+	fw.debounce.Stop()
 
 	// Abort any running build
 	fw.mu.Lock()
-	if fw.currentBuild != nil {
-		fw.currentBuild.Kill()
-		fw.currentBuild = nil
-	}
+	aborted := fw.currentBuild
+	fw.currentBuild = nil
 	fw.mu.Unlock()
+	if aborted != nil {
+		aborted.Kill()
+	}
 
 	// Stop process manager
 	fw.processManager.Stop()
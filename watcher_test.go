@@ -0,0 +1,348 @@
+package godevwatch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWatcher creates a file watcher for rules in a temporary project directory
+func newTestWatcher(t *testing.T, rules ...BuildRule) (*FileWatcher, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	config := DefaultConfig()
+	config.BuildStatusDir = filepath.Join(dir, "tmp", ".build-status")
+	config.BuildRules = rules
+	config.RunCmd = ""
+
+	fw, err := NewFileWatcher(config, NewBuildTracker(config.BuildStatusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fw.Stop() })
+	return fw, dir
+}
+
+// countRuns returns how many times a test rule appended to runs.log
+func countRuns(t *testing.T, dir string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, "runs.log"))
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "run")
+}
+
+func TestExecuteBuildCache(t *testing.T) {
+	unchanged := func(dir string) []string { return []string{filepath.Join(dir, "in.txt")} }
+
+	tests := []struct {
+		name            string
+		changed         func(dir string) []string
+		noCache         bool
+		stopBeforeBuild bool
+		ran             bool
+		restarted       bool
+	}{
+		{"initial build", func(dir string) []string { return nil }, false, false, false, true},
+		{"unchanged file", unchanged, false, false, false, false},
+		{"unchanged file with the app stopped", unchanged, false, true, false, true},
+		{"manual rebuild", func(dir string) []string { return []string{} }, false, false, true, true},
+		{"no cache", unchanged, true, false, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, dir := newTestWatcher(t, BuildRule{
+				Name:    "copy",
+				Watch:   []string{"*.txt"},
+				Command: "echo run >> runs.log && cp in.txt out.bin",
+				Inputs:  []string{"in.txt"},
+				Outputs: []string{"out.bin"},
+			})
+			fw.config.RunCmd = "sleep 30"
+			fw.config.StopBeforeBuild = tt.stopBeforeBuild
+			pm, err := NewProcessManager(fw.config, fw.buildTracker)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.processManager = pm
+			writeFile(t, dir, "in.txt", "v1")
+
+			// The first build records the inputs
+			fw.executeBuild(nil)
+			if runs := countRuns(t, dir); runs != 1 {
+				t.Fatalf("first build ran %d times", runs)
+			}
+			previous, _ := pm.app.current()
+
+			fw.config.NoCache = tt.noCache
+			fw.executeBuild(tt.changed(dir))
+			if ran := countRuns(t, dir) == 2; ran != tt.ran {
+				t.Fatalf("rule ran = %v, want %v", ran, tt.ran)
+			}
+			// A build that rebuilt nothing leaves the running app alone
+			current, _ := pm.app.current()
+			if current == nil {
+				t.Fatal("app not running")
+			}
+			if restarted := current != previous; restarted != tt.restarted {
+				t.Fatalf("app restarted = %v, want %v", restarted, tt.restarted)
+			}
+		})
+	}
+}
+
+func TestNewFileWatcherRequiresOutputsWithInputs(t *testing.T) {
+	config := DefaultConfig()
+	config.BuildStatusDir = t.TempDir()
+	config.BuildRules = []BuildRule{{Name: "css", Command: "sass", Inputs: []string{"*.scss"}}}
+
+	_, err := NewFileWatcher(config, NewBuildTracker(config.BuildStatusDir))
+	if err == nil || !strings.Contains(err.Error(), "inputs require at least one output") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestQueueChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches [][]string
+		want    []string
+	}{
+		{"single change set", [][]string{{"a.go"}}, []string{"a.go"}},
+		{"merged without duplicates", [][]string{{"a.go", "b.go"}, {"b.go", "c.go"}}, []string{"a.go", "b.go", "c.go"}},
+		{"initial build wins", [][]string{{"a.go"}, nil, {"b.go"}}, nil},
+		{"manual rebuild wins over files", [][]string{{"a.go"}, {}, {"b.go"}}, []string{}},
+		{"initial build wins over manual rebuild", [][]string{{}, nil}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw := &FileWatcher{}
+			for _, files := range tt.batches {
+				fw.queueChanges(files)
+			}
+
+			files, queued := fw.takeQueued()
+			if !queued {
+				t.Fatal("nothing queued")
+			}
+			if (files == nil) != (tt.want == nil) || strings.Join(files, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("queued %#v, want %#v", files, tt.want)
+			}
+			if _, queued := fw.takeQueued(); queued {
+				t.Fatal("queue not emptied")
+			}
+		})
+	}
+}
+
+func TestChangesDuringBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		onChange string
+		runs     int
+	}{
+		// An aborted rule never appends to runs.log
+		{"abort", OnChangeAbort, 0},
+		// A queue rule finishes, but the rule after it does not run
+		{"queue", OnChangeQueue, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, dir := newTestWatcher(t,
+				BuildRule{Name: "slow", Watch: []string{"*.go"}, Command: "sleep 1 && echo run >> runs.log", OnChange: tt.onChange},
+				BuildRule{Name: "next", Watch: []string{"*.go"}, Command: "echo run >> runs.log"},
+			)
+			changed := []string{filepath.Join(dir, "main.go")}
+
+			done := make(chan struct{})
+			go func() {
+				fw.executeBuild(changed)
+				close(done)
+			}()
+			time.Sleep(300 * time.Millisecond)
+			fw.triggerBuild([]string{filepath.Join(dir, "other.go")})
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("build did not stop")
+			}
+			if runs := countRuns(t, dir); runs != tt.runs {
+				t.Fatalf("rules ran %d times, want %d", runs, tt.runs)
+			}
+
+			// The next build covers the unfinished changes too
+			files, queued := fw.takeQueued()
+			if !queued || len(files) != 2 {
+				t.Fatalf("queued %v", files)
+			}
+		})
+	}
+}
+
+func TestOutputLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		lines       int
+		nextWindow  int
+		wantLines   int
+		wantDropped []int
+	}{
+		{"under the limit", 50, 0, 50, nil},
+		{"at the limit", 100, 0, 100, nil},
+		{"over the limit", 150, 0, 100, []int{50}},
+		{"limit resets each second", 120, 30, 130, []int{20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dropped []int
+			ol := newOutputLimiter(100, func(n int) { dropped = append(dropped, n) })
+
+			lines := 0
+			for i := 0; i < tt.lines; i++ {
+				if ol.Allow() {
+					lines++
+				}
+			}
+			if tt.nextWindow > 0 {
+				// Skipped lines are reported when the next window starts
+				ol.mu.Lock()
+				ol.windowStart = ol.windowStart.Add(-time.Second)
+				ol.mu.Unlock()
+				for i := 0; i < tt.nextWindow; i++ {
+					if ol.Allow() {
+						lines++
+					}
+				}
+			}
+			ol.Flush()
+
+			if lines != tt.wantLines || fmt.Sprint(dropped) != fmt.Sprint(tt.wantDropped) {
+				t.Fatalf("allowed %d lines and dropped %v, want %d and %v", lines, dropped, tt.wantLines, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestBuildOutputLimited(t *testing.T) {
+	fw, _ := newTestWatcher(t, BuildRule{Name: "noisy", Watch: []string{"*.go"}, Command: "seq 150"})
+	events, unsubscribe := fw.buildTracker.Events().Subscribe()
+	defer unsubscribe()
+
+	fw.executeBuild(nil)
+
+	var lines int
+	var dropped []int
+	for len(events) > 0 {
+		event := <-events
+		if event.Type != MessageBuildOutput {
+			continue
+		}
+		data := event.Data.(BuildOutputData)
+		if data.Rule != "noisy" {
+			t.Fatalf("output = %+v", data)
+		}
+		if data.Dropped == 0 {
+			lines++
+			continue
+		}
+		if data.Stream != "stderr" || data.Line != "... 50 lines skipped" {
+			t.Fatalf("summary = %+v", data)
+		}
+		dropped = append(dropped, data.Dropped)
+	}
+	if lines != buildOutputRate || fmt.Sprint(dropped) != "[50]" {
+		t.Fatalf("published %d lines and dropped %v", lines, dropped)
+	}
+}
+
+func TestStopBeforeBuild(t *testing.T) {
+	tests := []struct {
+		name            string
+		stopBeforeBuild bool
+		buildFails      bool
+		keptRunning     bool
+	}{
+		{"stopped before a failing build", true, true, false},
+		{"stopped before a successful build", true, false, false},
+		{"kept running through a failing build", false, true, true},
+		{"replaced after a successful build", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, dir := newTestWatcher(t, BuildRule{Name: "check", Watch: []string{"*.go"}, Command: "test ! -f broken"})
+			fw.config.StopBeforeBuild = tt.stopBeforeBuild
+			fw.config.RunCmd = "sleep 30"
+			pm, err := NewProcessManager(fw.config, fw.buildTracker)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.processManager = pm
+
+			fw.executeBuild(nil)
+			previous, _ := pm.app.current()
+			if previous == nil {
+				t.Fatal("app not started by the initial build")
+			}
+
+			if tt.buildFails {
+				writeFile(t, dir, "broken", "")
+			}
+			fw.executeBuild([]string{filepath.Join(dir, "main.go")})
+
+			current, _ := pm.app.current()
+			if running := current == previous && !previous.Exited(); running != tt.keptRunning {
+				t.Fatalf("previous app kept running = %v, want %v", running, tt.keptRunning)
+			}
+			if !tt.buildFails && (current == nil || current == previous) {
+				t.Fatal("rebuilt app not started")
+			}
+		})
+	}
+}
+
+func TestRestartAppWaitsForBuild(t *testing.T) {
+	fw, dir := newTestWatcher(t, BuildRule{Name: "slow", Watch: []string{"*.go"}, Command: "echo build >> order.log && sleep 0.5 && echo built >> order.log"})
+	fw.config.RunCmd = "echo app >> order.log; sleep 30"
+	pm, err := NewProcessManager(fw.config, fw.buildTracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.processManager = pm
+	go fw.processBuildTriggers()
+
+	fw.triggerBuild(nil)
+	time.Sleep(200 * time.Millisecond)
+	fw.RestartApp()
+
+	// The restart only starts the app once the build has finished
+	var got string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		data, _ := os.ReadFile(filepath.Join(dir, "order.log"))
+		if got = strings.Join(strings.Fields(string(data)), " "); strings.Contains(got, "app") {
+			break
+		}
+	}
+	if !strings.HasPrefix(got, "build built app") {
+		t.Fatalf("order = %q, want the app started after the build", got)
+	}
+	if current, _ := pm.app.current(); current == nil || current.Exited() {
+		t.Fatal("app not running after restart")
+	}
+}